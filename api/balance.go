@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/gin-gonic/gin"
+)
+
+const KEY_ADDRESS = "address"
+
+type BalanceResponse struct {
+	Address string `json:"address,omitempty"`
+	// DerivedAddresses are the addresses with unspent outputs derived from the xpub or the descriptor
+	DerivedAddresses []*script.DerivedAddress `json:"derived_addresses,omitempty"`
+	*_mongo.AddressBalance
+	IndexedHeight int    `json:"indexed_height"`
+	Warning       string `json:"warning,omitempty"`
+}
+
+// AddressBalanceHandler sums the unspent outputs of an address, in satoshis
+func (s Server) AddressBalanceHandler(c *gin.Context) {
+	address := c.Param(KEY_ADDRESS)
+	indexedHeight := s.IndexedHeight()
+	balance, err := s.mongoServer.GetAddressBalance(c, []string{address}, indexedHeight)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, BalanceResponse{
+		Address:        address,
+		AddressBalance: balance,
+		IndexedHeight:  indexedHeight,
+	})
+}
+
+// BalanceHandler sums the unspent outputs of several addresses, or of the addresses of an xpub or a descriptor
+func (s Server) BalanceHandler(c *gin.Context) {
+	payload := &Owners{}
+	if err := c.BindJSON(&payload); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if payload.isEmpty() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "address, addresses, xpub and descriptor cannot all be empty"})
+		return
+	}
+	descriptors, err := payload.descriptors(s.network)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	indexedHeight := s.IndexedHeight()
+	addresses, derivedAddresses, err := s.resolveOwners(c, payload, descriptors)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTooManyAddresses) {
+			status = http.StatusBadRequest
+		}
+		c.AbortWithStatusJSON(status, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	balance := &_mongo.AddressBalance{}
+	if len(addresses) > 0 {
+		if balance, err = s.mongoServer.GetAddressBalance(c, addresses, indexedHeight); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+	}
+
+	var derived []*script.DerivedAddress
+	for _, address := range addresses {
+		if derivedAddress, ok := derivedAddresses[address]; ok {
+			derived = append(derived, derivedAddress)
+		}
+	}
+	c.JSON(http.StatusOK, BalanceResponse{
+		DerivedAddresses: derived,
+		AddressBalance:   balance,
+		IndexedHeight:    indexedHeight,
+		Warning:          s.scanWarning(descriptors),
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/gin-gonic/gin"
+)
+
+// REJECT_ALREADY_IN_MEMPOOL is testmempoolaccept's reason for transactions the node already has, they are
+// broadcast again rather than rejected
+const REJECT_ALREADY_IN_MEMPOOL = "txn-already-in-mempool"
+
+type BroadcastRequest struct {
+	RawTx string `json:"raw_tx"` // hex
+}
+
+type BroadcastResponse struct {
+	TxID  string `json:"tx_id"`
+	Wtxid string `json:"wtxid,omitempty"`
+	Vsize int64  `json:"vsize,omitempty"`
+	Fee   int64  `json:"fee,omitempty"` // in satoshis
+}
+
+// BroadcastRejection explains why the node refused a transaction
+type BroadcastRejection struct {
+	Error         string `json:"error"`
+	TxID          string `json:"tx_id,omitempty"`
+	RejectReason  string `json:"reject_reason"`
+	RejectDetails string `json:"reject_details,omitempty"`
+	// Code is the rpc error code when sendrawtransaction refused the transaction after testmempoolaccept allowed it
+	Code int `json:"code,omitempty"`
+}
+
+// BroadcastHandler validates a signed transaction against the node's mempool policy and relays it. With mempool
+// tracking, the outputs it spends are marked as pending right away
+func (s Server) BroadcastHandler(c *gin.Context) {
+	payload := &BroadcastRequest{}
+	if err := c.BindJSON(&payload); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if _, err := hex.DecodeString(payload.RawTx); err != nil || payload.RawTx == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "raw_tx must be a non-empty hex string"})
+		return
+	}
+
+	result, err := s.fullnode.TestMempoolAccept(c, payload.RawTx)
+	if err != nil {
+		var rpcErr *fullnode.RPCError
+		if errors.As(err, &rpcErr) && rpcErr.Code == fullnode.RPC_DESERIALIZATION_ERROR {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: rpcErr.Message})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if !result.Allowed && result.RejectReason != REJECT_ALREADY_IN_MEMPOOL {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, BroadcastRejection{
+			Error:         "transaction rejected",
+			TxID:          result.Txid,
+			RejectReason:  result.RejectReason,
+			RejectDetails: result.RejectDetails,
+		})
+		return
+	}
+
+	txid, err := s.fullnode.SendRawTransaction(c, payload.RawTx)
+	if err != nil {
+		var rpcErr *fullnode.RPCError
+		if errors.As(err, &rpcErr) {
+			// e.g. a conflicting transaction entered the mempool in between, or the transaction is already confirmed
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, BroadcastRejection{
+				Error:        "transaction rejected",
+				TxID:         result.Txid,
+				RejectReason: rpcErr.Message,
+				Code:         rpcErr.Code,
+			})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	if s.mempool != nil {
+		// the transaction is out, failing to record it only delays its spends until the next mempool poll
+		if err := s.mempool.Track(c, txid); err != nil {
+			log.Println("[warning] failed to record broadcast transaction with error: ", err.Error())
+		}
+	}
+
+	response := &BroadcastResponse{TxID: txid, Wtxid: result.Wtxid, Vsize: result.Vsize}
+	if result.Fees != nil {
+		response.Fee = int64(result.Fees.Base)
+	}
+	c.JSON(http.StatusOK, response)
+}
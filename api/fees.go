@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeesHandler returns fee rates for several confirmation targets, estimated once per block of the node
+func (s Server) FeesHandler(c *gin.Context) {
+	estimates, err := s.fees.Estimate(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, estimates)
+}
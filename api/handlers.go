@@ -1,12 +1,20 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
+	"os"
 
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/ABMatrix/bitcoin-utxo-ms/fees"
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mempool"
 	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -27,25 +35,77 @@ const (
 )
 
 type ListRequest struct {
-	Address string `json:"address,omitempty"`
-	Page    int64  `json:"page,omitempty"`
-	Limit   int64  `json:"limit,omitempty"`
-	Order   Order  `json:"order,omitempty"`
+	Owners
+	// ScriptHash selects the outputs by Electrum-style scripthash instead of, or on top of, the address
+	ScriptHash string `json:"scripthash,omitempty"`
+	Page       int64  `json:"page,omitempty"`
+	Limit      int64  `json:"limit,omitempty"`
+	Order      Order  `json:"order,omitempty"`
+	// Types only returns outputs of these script types, all of them when empty
+	Types []_mongo.ScriptType `json:"types,omitempty"`
+	// IncludeUnconfirmed additionally returns the outputs of mempool transactions, outside of the pagination
+	IncludeUnconfirmed bool `json:"include_unconfirmed,omitempty"`
+	// ExcludeMempoolSpent leaves out outputs already spent by a mempool transaction
+	ExcludeMempoolSpent bool `json:"exclude_mempool_spent,omitempty"`
 }
 
 type ListResponse struct {
-	UTXOS    []*_mongo.UTXO `json:"utxos,omitempty"`
-	Total    int64          `json:"total,omitempty"`
-	Page     int64          `json:"page,omitempty"`
-	LastPage int64          `json:"last_page,omitempty"`
+	UTXOS         []*UTXO `json:"utxos,omitempty"`
+	Unconfirmed   []*UTXO `json:"unconfirmed_utxos,omitempty"`
+	Total         int64   `json:"total,omitempty"`
+	Page          int64   `json:"page,omitempty"`
+	LastPage      int64   `json:"last_page,omitempty"`
+	IndexedHeight int     `json:"indexed_height"`
+	Warning       string  `json:"warning,omitempty"`
+}
+
+// UTXO is an unspent output with the derivation path of its address, when it was derived from an xpub or a descriptor
+type UTXO struct {
+	*_mongo.UTXO
+	DerivationPath string `json:"derivation_path,omitempty"`
 }
 
 type Server struct {
-	utxoCollection *mongo.Collection
+	utxoCollection     *mongo.Collection
+	mempoolCollection  *mongo.Collection
+	spendsCollection   *mongo.Collection
+	opReturnCollection *mongo.Collection
+	addressCollection  *mongo.Collection
+	addressHistory     bool // whether the synchronizer records every address ever paid to
+	mongoServer        _mongo.Interface
+	syncer             synchronizer.Interface
+	fullnode           fullnode.Interface
+	mempool            mempool.Interface // nil without mempool tracking
+	fees               fees.Interface
+	network            *script.Network
+	derivedChains      *script.ChainCache
+}
+
+func New(mongoCli *mongo.Client, db string, collection string, mongoServer _mongo.Interface, syncer synchronizer.Interface, btcServer fullnode.Interface, mempoolWatcher mempool.Interface, feeEstimator fees.Interface, network *script.Network) *Server {
+	return &Server{
+		utxoCollection:     mongoCli.Database(db).Collection(collection),
+		mempoolCollection:  mongoCli.Database(db).Collection(collection + _mongo.MEMPOOL_COLLECTION_SUFFIX),
+		spendsCollection:   mongoCli.Database(db).Collection(collection + _mongo.MEMPOOL_SPENDS_COLLECTION_SUFFIX),
+		opReturnCollection: mongoCli.Database(db).Collection(collection + _mongo.OP_RETURN_COLLECTION_SUFFIX),
+		addressCollection:  mongoCli.Database(db).Collection(collection + _mongo.ADDRESS_COLLECTION_SUFFIX),
+		addressHistory:     os.Getenv(synchronizer.ENV_ADDRESS_HISTORY_INDEX) == "true",
+		mongoServer:        mongoServer,
+		syncer:             syncer,
+		fullnode:           btcServer,
+		mempool:            mempoolWatcher,
+		fees:               feeEstimator,
+		network:            network,
+		derivedChains:      script.NewChainCache(MAX_CACHED_CHAINS),
+	}
+}
+
+// IndexedHeight returns the height of the last block reflected in the UTXO collection
+func (s Server) IndexedHeight() int {
+	return s.syncer.Status().IndexedHeight
 }
 
-func New(mongoCli *mongo.Client, db string, collection string) *Server {
-	return &Server{utxoCollection: mongoCli.Database(db).Collection(collection)}
+func (s Server) StatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.syncer.Status())
 }
 
 func (s Server) ListHandler(c *gin.Context) {
@@ -54,13 +114,60 @@ func (s Server) ListHandler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
 		return
 	}
-	if payload.Address == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "address cannot by empty"})
+	if payload.Owners.isEmpty() && payload.ScriptHash == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "address, addresses, xpub, descriptor and scripthash cannot all be empty"})
+		return
+	}
+	if payload.ScriptHash != "" && !_mongo.ScriptHashPattern.MatchString(payload.ScriptHash) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "scripthash must be 64 lowercase hex digits"})
+		return
+	}
+
+	for _, scriptType := range payload.Types {
+		if !scriptType.IsValid() {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: fmt.Sprintf("unknown script type %q", scriptType)})
+			return
+		}
+	}
+
+	descriptors, err := payload.Owners.descriptors(s.network)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	indexedHeight := s.IndexedHeight()
+	addresses, derived, err := s.resolveOwners(c, &payload.Owners, descriptors)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTooManyAddresses) {
+			status = http.StatusBadRequest
+		}
+		c.AbortWithStatusJSON(status, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if len(addresses) == 0 && payload.ScriptHash == "" {
+		// nothing was derived with unspent outputs
+		c.JSON(http.StatusOK, ListResponse{IndexedHeight: indexedHeight, Warning: s.scanWarning(descriptors)})
 		return
 	}
 
-	filter := bson.M{_mongo.KEY_ADDRESS: payload.Address, _mongo.KEY_AMOUNT: bson.M{_mongo.KEY_GT: 0}}
-	var findOption *options.FindOptions
+	filter := ownerFilter(addresses, payload.ScriptHash)
+	filter[KEY_AMOUNT] = bson.M{_mongo.KEY_GT: 0}
+	if len(payload.Types) > 0 {
+		filter[_mongo.KEY_TYPE] = bson.M{_mongo.KEY_IN: payload.Types}
+	}
+	if payload.ExcludeMempoolSpent {
+		spentKeys, err := s.pendingSpends(c, addresses, payload.ScriptHash)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		if len(spentKeys) > 0 {
+			filter[_mongo.KEY_NOR] = spentKeys
+		}
+	}
+	findOption := options.Find()
 	var page = DefaultPage
 	var limit = DefaultLimit
 	if payload.Page > 0 {
@@ -70,6 +177,7 @@ func (s Server) ListHandler(c *gin.Context) {
 		limit = payload.Limit
 	}
 	findOption.SetSkip((page - 1) * limit)
+	findOption.SetLimit(limit)
 
 	var sortOrder Order = OrderAsc
 	if payload.Order == OrderAsc || payload.Order == OrderDesc {
@@ -88,20 +196,75 @@ func (s Server) ListHandler(c *gin.Context) {
 		return
 	}
 
-	var utxos []*_mongo.UTXO
+	var utxos []*UTXO
 	for cur.Next(c) {
 		utxo := &_mongo.UTXO{}
 		if err := cur.Decode(&utxo); err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
 			return
 		}
-		utxos = append(utxos, utxo)
+		utxos = append(utxos, &UTXO{UTXO: utxo, DerivationPath: derivationPath(utxo, derived)})
+	}
+
+	var unconfirmed []*UTXO
+	if payload.IncludeUnconfirmed {
+		cur, err := s.mempoolCollection.Find(c, filter, options.Find().SetSort(bson.M{KEY_AMOUNT: sortOrder}))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		var mempoolUtxos []*_mongo.UTXO
+		if err := cur.All(c, &mempoolUtxos); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		for _, utxo := range mempoolUtxos {
+			unconfirmed = append(unconfirmed, &UTXO{UTXO: utxo, DerivationPath: derivationPath(utxo, derived)})
+		}
 	}
 
 	c.JSON(http.StatusOK, ListResponse{
-		UTXOS:    utxos,
-		Total:    total,
-		Page:     page,
-		LastPage: int64(math.Ceil(float64(total / limit))),
+		UTXOS:         utxos,
+		Unconfirmed:   unconfirmed,
+		Total:         total,
+		Page:          page,
+		LastPage:      int64(math.Ceil(float64(total) / float64(limit))),
+		IndexedHeight: indexedHeight,
+		Warning:       s.scanWarning(descriptors),
 	})
 }
+
+// pendingSpends returns the keys of the outputs of `addresses` and/or `scriptHash` spent by mempool transactions
+func (s Server) pendingSpends(c *gin.Context, addresses []string, scriptHash string) ([]bson.M, error) {
+	cur, err := s.spendsCollection.Find(c, ownerFilter(addresses, scriptHash))
+	if err != nil {
+		return nil, err
+	}
+
+	var spends []*_mongo.PendingSpend
+	if err := cur.All(c, &spends); err != nil {
+		return nil, err
+	}
+
+	var keys []bson.M
+	for _, spend := range spends {
+		keys = append(keys, bson.M{_mongo.KEY_TXID: spend.TxID, _mongo.KEY_VOUT: spend.Vout})
+	}
+	return keys, nil
+}
+
+// ownerFilter matches the outputs paying to one of `addresses`, either alone or as one of the keys of a bare multisig,
+// and/or to `scriptHash`
+func ownerFilter(addresses []string, scriptHash string) bson.M {
+	filter := bson.M{}
+	if len(addresses) == 1 {
+		filter[_mongo.KEY_OR] = []bson.M{{_mongo.KEY_ADDRESS: addresses[0]}, {_mongo.KEY_ADDRESSES: addresses[0]}}
+	} else if len(addresses) > 1 {
+		in := bson.M{_mongo.KEY_IN: addresses}
+		filter[_mongo.KEY_OR] = []bson.M{{_mongo.KEY_ADDRESS: in}, {_mongo.KEY_ADDRESSES: in}}
+	}
+	if scriptHash != "" {
+		filter[_mongo.KEY_SCRIPTHASH] = scriptHash
+	}
+	return filter
+}
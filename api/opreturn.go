@@ -0,0 +1,86 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const KEY_REGEX = "$regex"
+
+var hexPattern = regexp.MustCompile("^[0-9a-f]*$")
+
+type OpReturnSearchRequest struct {
+	Prefix string `json:"prefix,omitempty"` // hex of the payload prefix
+	Page   int64  `json:"page,omitempty"`
+	Limit  int64  `json:"limit,omitempty"`
+}
+
+type OpReturnSearchResponse struct {
+	OpReturns     []*_mongo.OpReturn `json:"op_returns,omitempty"`
+	Total         int64              `json:"total,omitempty"`
+	Page          int64              `json:"page,omitempty"`
+	LastPage      int64              `json:"last_page,omitempty"`
+	IndexedHeight int                `json:"indexed_height"`
+}
+
+// OpReturnSearchHandler lists the indexed OP_RETURN outputs whose payload starts with the given bytes
+func (s Server) OpReturnSearchHandler(c *gin.Context) {
+	payload := &OpReturnSearchRequest{}
+	if err := c.BindJSON(&payload); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	prefix := strings.ToLower(payload.Prefix)
+	if prefix == "" || !hexPattern.MatchString(prefix) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "prefix must be a non-empty hex string"})
+		return
+	}
+
+	indexedHeight := s.IndexedHeight()
+	// an anchored regex on hex digits only is answered from the payload index, which also gives the order
+	filter := bson.M{_mongo.KEY_PAYLOAD: bson.M{KEY_REGEX: "^" + prefix}}
+	var page = DefaultPage
+	var limit = DefaultLimit
+	if payload.Page > 0 {
+		page = payload.Page
+	}
+	if payload.Limit > 0 {
+		limit = payload.Limit
+	}
+	findOption := options.Find().
+		SetSkip((page - 1) * limit).
+		SetLimit(limit).
+		SetSort(bson.D{{Key: _mongo.KEY_PAYLOAD, Value: 1}, {Key: _mongo.KEY_HEIGHT, Value: 1}})
+
+	cur, err := s.opReturnCollection.Find(c, filter, findOption)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	var opReturns []*_mongo.OpReturn
+	if err := cur.All(c, &opReturns); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	total, err := s.opReturnCollection.CountDocuments(c, filter)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, OpReturnSearchResponse{
+		OpReturns:     opReturns,
+		Total:         total,
+		Page:          page,
+		LastPage:      int64(math.Ceil(float64(total) / float64(limit))),
+		IndexedHeight: indexedHeight,
+	})
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/coinselect"
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/psbt"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Outpoint struct {
+	TxID string `json:"tx_id"`
+	Vout int    `json:"vout"`
+}
+
+type PsbtOutput struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"` // in satoshis
+}
+
+// PsbtRequest spends either all the given outpoints, or outputs of the owners picked by coin selection
+type PsbtRequest struct {
+	Owners
+	Outpoints []*Outpoint   `json:"outpoints,omitempty"`
+	Outputs   []*PsbtOutput `json:"outputs"`
+	// FeeRate, in sat/vB, and ChangeAddress size the fee and receive what's left of the inputs
+	FeeRate         float64              `json:"fee_rate,omitempty"`
+	ChangeAddress   string               `json:"change_address,omitempty"`
+	Fallback        coinselect.Algorithm `json:"fallback,omitempty"`
+	ExcludeImmature bool                 `json:"exclude_immature,omitempty"`
+	ExcludeDust     bool                 `json:"exclude_dust,omitempty"`
+	LockTime        uint32               `json:"locktime,omitempty"`
+}
+
+type PsbtResponse struct {
+	Psbt          string  `json:"psbt"` // base64
+	Inputs        []*UTXO `json:"inputs"`
+	Fee           int64   `json:"fee"`
+	Change        int64   `json:"change"` // 0 when there's no change output, which is always the last output
+	Vsize         int64   `json:"vsize"`  // estimated
+	IndexedHeight int     `json:"indexed_height"`
+	Warning       string  `json:"warning,omitempty"`
+}
+
+// PsbtCreateHandler builds an unsigned transaction as a BIP174 PSBT with everything offline signers need about the
+// spent outputs: witness_utxo for segwit inputs and the previous transaction (non_witness_utxo) for legacy ones
+func (s Server) PsbtCreateHandler(c *gin.Context) {
+	payload := &PsbtRequest{}
+	if err := c.BindJSON(&payload); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if payload.Owners.isEmpty() == (len(payload.Outpoints) == 0) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "either outpoints or address, addresses, xpub or descriptor must be given"})
+		return
+	}
+	if len(payload.Outpoints) > MAX_ADDRESSES {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: fmt.Sprintf("at most %d outpoints can be spent at once", MAX_ADDRESSES)})
+		return
+	}
+	if len(payload.Outputs) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "outputs cannot be empty"})
+		return
+	}
+
+	var outputs []*psbt.Output
+	var outputScripts [][]byte
+	var target int64
+	for _, output := range payload.Outputs {
+		if output.Amount <= 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "output amounts must be positive"})
+			return
+		}
+		pkScript, err := script.AddressScript(output.Address, s.network)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: fmt.Sprintf("invalid address %q: %s", output.Address, err.Error())})
+			return
+		}
+		outputs = append(outputs, &psbt.Output{Amount: output.Amount, Script: pkScript})
+		outputScripts = append(outputScripts, pkScript)
+		target += output.Amount
+	}
+
+	if payload.ChangeAddress == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "change_address cannot be empty"})
+		return
+	}
+	changeScript, err := script.AddressScript(payload.ChangeAddress, s.network)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: fmt.Sprintf("invalid change address: %s", err.Error())})
+		return
+	}
+	changeType, ok := outputType(changeScript)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "unsupported change address type"})
+		return
+	}
+	indexedHeight := s.IndexedHeight()
+	params := &coinselect.Params{
+		Target:          target,
+		FeeRate:         payload.FeeRate,
+		OutputScripts:   outputScripts,
+		ChangeType:      changeType,
+		Fallback:        payload.Fallback,
+		ExcludeDust:     payload.ExcludeDust,
+		ExcludeImmature: payload.ExcludeImmature,
+		SpendHeight:     indexedHeight + 1,
+	}
+	if params.Fallback == "" {
+		params.Fallback = coinselect.Algorithm_Knapsack
+	}
+	if err := params.Validate(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	derived := map[string]*script.DerivedAddress{}
+	var selection *coinselect.Selection
+	var warning string
+	if len(payload.Outpoints) > 0 {
+		utxos, err := s.findOutpoints(c, payload.Outpoints)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		if selection, err = coinselect.Fund(utxos, params); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+	} else {
+		descriptors, err := payload.Owners.descriptors(s.network)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+
+		addresses, derivedAddresses, err := s.resolveOwners(c, &payload.Owners, descriptors)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errTooManyAddresses) {
+				status = http.StatusBadRequest
+			}
+			c.AbortWithStatusJSON(status, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		derived = derivedAddresses
+		warning = s.scanWarning(descriptors)
+		if len(addresses) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: coinselect.ErrInsufficientFunds.Error()})
+			return
+		}
+		candidates, err := s.spendableUtxos(c, addresses)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+		if selection, err = coinselect.Select(candidates, params); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+			return
+		}
+	}
+
+	utxos := selection.Inputs
+	if selection.Change > 0 {
+		outputs = append(outputs, &psbt.Output{Amount: selection.Change, Script: changeScript})
+	}
+	response := &PsbtResponse{
+		Fee:           selection.Fee,
+		Change:        selection.Change,
+		Vsize:         selection.Vsize,
+		IndexedHeight: indexedHeight,
+		Warning:       warning,
+	}
+
+	inputs, err := s.psbtInputs(c, utxos, derived)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	serialized, err := psbt.Create(inputs, outputs, payload.LockTime)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	response.Psbt = base64.StdEncoding.EncodeToString(serialized)
+	for _, utxo := range utxos {
+		response.Inputs = append(response.Inputs, &UTXO{UTXO: utxo, DerivationPath: derivationPath(utxo, derived)})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// findOutpoints returns the confirmed outputs of `outpoints`, in the same order, and fails when one isn't unspent,
+// is spent by a mempool transaction or is given twice
+func (s Server) findOutpoints(c *gin.Context, outpoints []*Outpoint) ([]*_mongo.UTXO, error) {
+	seen := map[Outpoint]bool{}
+	var keys []bson.M
+	for _, outpoint := range outpoints {
+		if seen[*outpoint] {
+			return nil, fmt.Errorf("%s:%d is spent twice", outpoint.TxID, outpoint.Vout)
+		}
+		seen[*outpoint] = true
+		keys = append(keys, bson.M{_mongo.KEY_TXID: outpoint.TxID, _mongo.KEY_VOUT: outpoint.Vout})
+	}
+	cur, err := s.spendsCollection.Find(c, bson.M{_mongo.KEY_OR: keys})
+	if err != nil {
+		return nil, err
+	}
+	var spends []*_mongo.PendingSpend
+	if err := cur.All(c, &spends); err != nil {
+		return nil, err
+	}
+	if len(spends) > 0 {
+		return nil, fmt.Errorf("%s:%d is already spent by mempool transaction %s", spends[0].TxID, spends[0].Vout, spends[0].SpentBy)
+	}
+
+	if cur, err = s.utxoCollection.Find(c, bson.M{_mongo.KEY_OR: keys}); err != nil {
+		return nil, err
+	}
+	var found []*_mongo.UTXO
+	if err := cur.All(c, &found); err != nil {
+		return nil, err
+	}
+
+	byOutpoint := map[Outpoint]*_mongo.UTXO{}
+	for _, utxo := range found {
+		byOutpoint[Outpoint{TxID: utxo.TxID, Vout: utxo.Vout}] = utxo
+	}
+	var utxos []*_mongo.UTXO
+	for _, outpoint := range outpoints {
+		utxo, ok := byOutpoint[*outpoint]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d is not in the UTXO set", outpoint.TxID, outpoint.Vout)
+		}
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+// psbtInputs describes the spent outputs, fetching the transactions of the legacy ones from the node
+func (s Server) psbtInputs(c *gin.Context, utxos []*_mongo.UTXO, derived map[string]*script.DerivedAddress) ([]*psbt.Input, error) {
+	blockHashes := map[int]string{}
+	var inputs []*psbt.Input
+	for _, utxo := range utxos {
+		pkScript, err := hex.DecodeString(utxo.Script)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script of %s:%d: %w", utxo.TxID, utxo.Vout, err)
+		}
+		input := &psbt.Input{
+			TxID:        utxo.TxID,
+			Vout:        uint32(utxo.Vout),
+			Amount:      utxo.Amount,
+			Script:      pkScript,
+			WitnessUtxo: utxo.Type.IsWitnessProgram(),
+		}
+		if address := derivedAddress(utxo, derived); address != nil {
+			if address.AddressType == script.AddressType_P2SH_P2WPKH {
+				// a p2sh output is only known to wrap a witness program when it was derived as one
+				input.WitnessUtxo = true
+				input.RedeemScript = append([]byte{script.OP_0, 20}, script.Hash160(address.PubKey)...)
+			}
+			if address.Fingerprint != nil {
+				input.Derivation = &psbt.Derivation{
+					PubKey:      address.PubKey,
+					Fingerprint: address.Fingerprint,
+					Path:        address.Indexes,
+					Taproot:     address.AddressType == script.AddressType_P2TR,
+				}
+			}
+		}
+
+		if !utxo.Type.IsWitnessProgram() {
+			blockHash, ok := blockHashes[utxo.Height]
+			if !ok {
+				if blockHash, err = s.fullnode.GetBlockHash(c, utxo.Height); err != nil {
+					return nil, err
+				}
+				blockHashes[utxo.Height] = blockHash
+			}
+			rawTx, err := s.fullnode.GetRawTransactionHex(c, utxo.TxID, blockHash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get transaction %s: %w", utxo.TxID, err)
+			}
+			if input.NonWitnessUtxo, err = hex.DecodeString(rawTx); err != nil {
+				return nil, err
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// outputType classifies the scripts of the addresses AddressScript decodes, false for future witness versions
+func outputType(pkScript []byte) (_mongo.ScriptType, bool) {
+	switch {
+	case len(pkScript) == 25 && pkScript[0] == script.OP_DUP:
+		return _mongo.ScriptType_P2PKH, true
+	case len(pkScript) == 23 && pkScript[0] == script.OP_HASH160:
+		return _mongo.ScriptType_P2SH, true
+	case len(pkScript) == 22 && pkScript[0] == script.OP_0:
+		return _mongo.ScriptType_P2WKH, true
+	case len(pkScript) == 34 && pkScript[0] == script.OP_0:
+		return _mongo.ScriptType_P2WSH, true
+	case len(pkScript) == 34 && pkScript[0] == script.OP_1:
+		return _mongo.ScriptType_P2TR, true
+	}
+	return "", false
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/gin-gonic/gin"
+)
+
+const KEY_HASH = "hash"
+
+// ScriptHashUnspentHandler lists the outputs paying to a scripthash, including unconfirmed ones
+// and leaving out the ones spent by mempool transactions
+func (s Server) ScriptHashUnspentHandler(c *gin.Context) {
+	scriptHash := c.Param(KEY_HASH)
+	if !_mongo.ScriptHashPattern.MatchString(scriptHash) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "scripthash must be 64 lowercase hex digits"})
+		return
+	}
+
+	utxos, err := s.mongoServer.ListUnspentByScriptHash(c, scriptHash)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, _mongo.NewUnspentOutputs(utxos))
+}
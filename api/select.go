@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/coinselect"
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MAX_SELECT_CANDIDATES bounds the outputs coin selection runs on, the largest ones are kept
+const MAX_SELECT_CANDIDATES = 5000
+
+type SelectRequest struct {
+	Owners
+	Target  int64   `json:"target"`   // in satoshis
+	FeeRate float64 `json:"fee_rate"` // in sat/vB
+	// ChangeType is the script type of the change output, p2wkh by default
+	ChangeType _mongo.ScriptType `json:"change_type,omitempty"`
+	// OutputType is the script type of the payment output, the change type by default
+	OutputType _mongo.ScriptType `json:"output_type,omitempty"`
+	// Fallback is the algorithm used when branch-and-bound finds no selection without change, knapsack by default
+	Fallback        coinselect.Algorithm `json:"fallback,omitempty"`
+	ExcludeImmature bool                 `json:"exclude_immature,omitempty"`
+	ExcludeDust     bool                 `json:"exclude_dust,omitempty"`
+}
+
+type SelectResponse struct {
+	*coinselect.Selection
+	Inputs        []*UTXO `json:"inputs"`
+	IndexedHeight int     `json:"indexed_height"`
+	Warning       string  `json:"warning,omitempty"`
+}
+
+// SelectHandler picks the confirmed outputs funding a payment, leaving out the ones spent by mempool transactions
+func (s Server) SelectHandler(c *gin.Context) {
+	payload := &SelectRequest{}
+	if err := c.BindJSON(&payload); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if payload.Owners.isEmpty() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: "address, addresses, xpub and descriptor cannot all be empty"})
+		return
+	}
+	indexedHeight := s.IndexedHeight()
+	params := &coinselect.Params{
+		Target:          payload.Target,
+		FeeRate:         payload.FeeRate,
+		OutputType:      payload.OutputType,
+		ChangeType:      payload.ChangeType,
+		Fallback:        payload.Fallback,
+		ExcludeDust:     payload.ExcludeDust,
+		ExcludeImmature: payload.ExcludeImmature,
+		// the payment confirms in the next block at the earliest
+		SpendHeight: indexedHeight + 1,
+	}
+	if params.ChangeType == "" {
+		params.ChangeType = _mongo.ScriptType_P2WKH
+	}
+	if params.OutputType == "" {
+		params.OutputType = params.ChangeType
+	}
+	if params.Fallback == "" {
+		params.Fallback = coinselect.Algorithm_Knapsack
+	}
+	if err := params.Validate(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	descriptors, err := payload.Owners.descriptors(s.network)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	addresses, derived, err := s.resolveOwners(c, &payload.Owners, descriptors)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errTooManyAddresses) {
+			status = http.StatusBadRequest
+		}
+		c.AbortWithStatusJSON(status, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+	if len(addresses) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: coinselect.ErrInsufficientFunds.Error()})
+		return
+	}
+
+	utxos, err := s.spendableUtxos(c, addresses)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	selection, err := coinselect.Select(utxos, params)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, map[string]string{KEY_ERROR: err.Error()})
+		return
+	}
+
+	inputs := make([]*UTXO, 0, len(selection.Inputs))
+	for _, utxo := range selection.Inputs {
+		inputs = append(inputs, &UTXO{UTXO: utxo, DerivationPath: derivationPath(utxo, derived)})
+	}
+	c.JSON(http.StatusOK, SelectResponse{
+		Selection:     selection,
+		Inputs:        inputs,
+		IndexedHeight: indexedHeight,
+		Warning:       s.scanWarning(descriptors),
+	})
+}
+
+// spendableUtxos returns the largest confirmed outputs of `addresses` which mempool transactions don't spend
+func (s Server) spendableUtxos(c *gin.Context, addresses []string) ([]*_mongo.UTXO, error) {
+	filter := ownerFilter(addresses, "")
+	filter[KEY_AMOUNT] = bson.M{_mongo.KEY_GT: 0}
+	excluded, err := s.pendingSpends(c, addresses, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) > 0 {
+		filter[_mongo.KEY_NOR] = excluded
+	}
+
+	cur, err := s.utxoCollection.Find(c, filter, options.Find().
+		SetSort(bson.M{KEY_AMOUNT: OrderDesc}).
+		SetLimit(MAX_SELECT_CANDIDATES))
+	if err != nil {
+		return nil, err
+	}
+	var utxos []*_mongo.UTXO
+	if err := cur.All(c, &utxos); err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"fmt"
+
+	_mongo "github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// MAX_ADDRESSES bounds the addresses listed in a request
+	MAX_ADDRESSES     = 1000
+	DEFAULT_GAP_LIMIT = 20
+	MAX_GAP_LIMIT     = 100
+	// MAX_DERIVED_ADDRESSES bounds the addresses derived for a request, used or not, each costs about a millisecond
+	MAX_DERIVED_ADDRESSES = 1000
+	// MAX_CACHED_CHAINS bounds the chains whose derived addresses are kept between requests
+	MAX_CACHED_CHAINS = 200
+)
+
+// WARNING_NO_ADDRESS_HISTORY flags the results of a scan which may have stopped at a run of emptied addresses
+const WARNING_NO_ADDRESS_HISTORY = "the address history index is disabled, derived addresses which have been emptied count in the gap limit and may hide later ones"
+
+var errTooManyAddresses = fmt.Errorf("more than %d addresses to derive, use a smaller gap limit", MAX_DERIVED_ADDRESSES)
+
+// Owners selects outputs by address, by a list of addresses and/or by the addresses derived from
+// an extended public key or an output descriptor
+type Owners struct {
+	Address   string   `json:"address,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Xpub is an account key (xpub, ypub, zpub or their testnet variants) whose receive and change chains are scanned
+	Xpub string `json:"xpub,omitempty"`
+	// XpubType overrides the address type implied by the version of Xpub, e.g. "p2tr" for BIP86 accounts
+	XpubType   script.AddressType `json:"xpub_type,omitempty"`
+	Descriptor string             `json:"descriptor,omitempty"`
+	// GapLimit is the number of consecutive unused addresses after which a chain is no longer scanned
+	GapLimit int `json:"gap_limit,omitempty"`
+}
+
+func (o *Owners) isEmpty() bool {
+	return o.Address == "" && len(o.Addresses) == 0 && o.Xpub == "" && o.Descriptor == ""
+}
+
+// descriptors checks the request and parses its xpub and descriptor
+func (o *Owners) descriptors(network *script.Network) ([]*script.Descriptor, error) {
+	if len(o.Addresses) > MAX_ADDRESSES {
+		return nil, fmt.Errorf("at most %d addresses can be queried at once", MAX_ADDRESSES)
+	}
+	if o.GapLimit < 0 || o.GapLimit > MAX_GAP_LIMIT {
+		return nil, fmt.Errorf("gap_limit must be between 0 (the default of %d) and %d", DEFAULT_GAP_LIMIT, MAX_GAP_LIMIT)
+	}
+	if o.XpubType != "" && !o.XpubType.IsValid() {
+		return nil, fmt.Errorf("unknown xpub_type %q", o.XpubType)
+	}
+
+	var descriptors []*script.Descriptor
+	if o.Xpub != "" {
+		key, err := script.ParseExtendedPubKey(o.Xpub, network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xpub: %w", err)
+		}
+		addressType := key.AddressType
+		if o.XpubType != "" {
+			addressType = o.XpubType
+		}
+		descriptors = append(descriptors, script.XpubDescriptor(key, addressType))
+	}
+	if o.Descriptor != "" {
+		descriptor, err := script.ParseDescriptor(o.Descriptor, network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid descriptor: %w", err)
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors, nil
+}
+
+// resolveOwners returns the addresses selected by `owners` and the derived ones by address.
+// Only derived addresses which were used are returned, they're the only ones which can hold outputs
+func (s Server) resolveOwners(c *gin.Context, owners *Owners, descriptors []*script.Descriptor) ([]string, map[string]*script.DerivedAddress, error) {
+	var addresses []string
+	if owners.Address != "" {
+		addresses = append(addresses, owners.Address)
+	}
+	addresses = append(addresses, owners.Addresses...)
+
+	gapLimit := uint32(DEFAULT_GAP_LIMIT)
+	if owners.GapLimit > 0 {
+		gapLimit = uint32(owners.GapLimit)
+	}
+	derivedAddresses := map[string]*script.DerivedAddress{}
+	derived := 0
+	for _, descriptor := range descriptors {
+		for _, chain := range descriptor.Chains {
+			used, count, err := s.scanChain(c, descriptor, chain, gapLimit, MAX_DERIVED_ADDRESSES-derived)
+			if err != nil {
+				return nil, nil, err
+			}
+			derived += count
+			for _, address := range used {
+				if _, ok := derivedAddresses[address.Address]; !ok {
+					addresses = append(addresses, address.Address)
+				}
+				derivedAddresses[address.Address] = address
+			}
+		}
+	}
+	return addresses, derivedAddresses, nil
+}
+
+// scanChain derives the addresses of `chain` until `gapLimit` consecutive ones are unused, it returns the used
+// ones and the number of addresses derived
+func (s Server) scanChain(c *gin.Context, descriptor *script.Descriptor, chain *script.DescriptorChain, gapLimit uint32, budget int) ([]*script.DerivedAddress, int, error) {
+	var used []*script.DerivedAddress
+	var next, end uint32 = 0, gapLimit
+	for next < end {
+		if int(end) > budget {
+			return nil, 0, errTooManyAddresses
+		}
+		batch, err := s.derivedChains.Addresses(descriptor, chain, next, end, s.network)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var addresses []string
+		for _, address := range batch {
+			addresses = append(addresses, address.Address)
+		}
+		found, err := s.usedAddresses(c, addresses)
+		if err != nil {
+			return nil, 0, err
+		}
+		for offset, address := range batch {
+			if found[address.Address] {
+				used = append(used, address)
+				end = next + uint32(offset) + 1 + gapLimit
+			}
+		}
+		next += uint32(len(batch))
+		if !chain.Ranged {
+			break
+		}
+	}
+	return used, int(next), nil
+}
+
+// usedAddresses tells which of `addresses` were ever paid to, when the address history is indexed, or otherwise
+// which have confirmed or unconfirmed unspent outputs. Every key of a bare multisig output counts as used
+func (s Server) usedAddresses(c *gin.Context, addresses []string) (map[string]bool, error) {
+	queried := map[string]bool{}
+	for _, address := range addresses {
+		queried[address] = true
+	}
+	in := bson.M{_mongo.KEY_IN: addresses}
+	type lookup struct {
+		collection *mongo.Collection
+		field      string
+	}
+	lookups := []lookup{
+		{s.utxoCollection, _mongo.KEY_ADDRESS},
+		{s.utxoCollection, _mongo.KEY_ADDRESSES},
+		{s.mempoolCollection, _mongo.KEY_ADDRESS},
+		{s.mempoolCollection, _mongo.KEY_ADDRESSES},
+	}
+	if s.addressHistory {
+		lookups = append(lookups, lookup{s.addressCollection, _mongo.KEY_ADDRESS})
+	}
+
+	used := map[string]bool{}
+	for _, l := range lookups {
+		values, err := l.collection.Distinct(c, l.field, bson.M{l.field: in})
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			// the distinct keys of multisig outputs include their other participants
+			if address, ok := value.(string); ok && queried[address] {
+				used[address] = true
+			}
+		}
+	}
+	return used, nil
+}
+
+// scanWarning returns the warning of the results of an xpub or descriptor scan, empty when they're complete
+func (s Server) scanWarning(descriptors []*script.Descriptor) string {
+	if len(descriptors) == 0 || s.addressHistory {
+		return ""
+	}
+	return WARNING_NO_ADDRESS_HISTORY
+}
+
+// derivedAddress returns the derived address `utxo` pays to, nil when it wasn't derived
+func derivedAddress(utxo *_mongo.UTXO, derived map[string]*script.DerivedAddress) *script.DerivedAddress {
+	if address, ok := derived[utxo.Address]; ok {
+		return address
+	}
+	for _, address := range utxo.Addresses {
+		if derivedAddress, ok := derived[address]; ok {
+			return derivedAddress
+		}
+	}
+	return nil
+}
+
+// derivationPath returns the path of the address of `utxo`, empty when it wasn't derived
+func derivationPath(utxo *_mongo.UTXO, derived map[string]*script.DerivedAddress) string {
+	if address := derivedAddress(utxo, derived); address != nil {
+		return address.Path
+	}
+	return ""
+}
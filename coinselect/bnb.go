@@ -0,0 +1,63 @@
+package coinselect
+
+// BNB_TOTAL_TRIES bounds the branches branch-and-bound explores, as in bitcoind
+const BNB_TOTAL_TRIES = 100000
+
+// branchAndBound searches the selections whose effective value lands in [target, target+costOfChange], so no change
+// output is needed, and returns the one wasting the least, nil when there's none. `candidates` are sorted by
+// decreasing effective value
+func branchAndBound(candidates []*candidate, target int64, costOfChange int64) []*candidate {
+	var available int64
+	for _, c := range candidates {
+		available += c.effective
+	}
+
+	var value int64
+	var selection []bool // whether each candidate up to len(selection) is included
+	var best []bool
+	bestWaste := int64(-1)
+	for try := 0; try < BNB_TOTAL_TRIES; try++ {
+		backtrack := false
+		if value+available < target || value > target+costOfChange {
+			backtrack = true
+		} else if value >= target {
+			if waste := value - target; bestWaste < 0 || waste <= bestWaste {
+				best = append([]bool{}, selection...)
+				bestWaste = waste
+			}
+			backtrack = true
+		}
+
+		if backtrack {
+			// walk back to the last included candidate, then try the branch without it
+			for len(selection) > 0 && !selection[len(selection)-1] {
+				selection = selection[:len(selection)-1]
+				available += candidates[len(selection)].effective
+			}
+			if len(selection) == 0 {
+				break
+			}
+			selection[len(selection)-1] = false
+			value -= candidates[len(selection)-1].effective
+			continue
+		}
+
+		next := candidates[len(selection)]
+		available -= next.effective
+		if len(selection) > 0 && !selection[len(selection)-1] && next.effective == candidates[len(selection)-1].effective {
+			// including it would explore the same selections as including the equivalent one just omitted
+			selection = append(selection, false)
+		} else {
+			selection = append(selection, true)
+			value += next.effective
+		}
+	}
+
+	var selected []*candidate
+	for index, included := range best {
+		if included {
+			selected = append(selected, candidates[index])
+		}
+	}
+	return selected
+}
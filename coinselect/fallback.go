@@ -0,0 +1,110 @@
+package coinselect
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// KNAPSACK_ITERATIONS is the number of random subsets tried, as in bitcoind
+const KNAPSACK_ITERATIONS = 1000
+
+// knapsack follows bitcoind's solver: an exact match, else the smaller candidates closest to `target` plus
+// `minChange`, else the smallest candidate larger than that
+func knapsack(candidates []*candidate, target int64, minChange int64) []*candidate {
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	shuffled := append([]*candidate{}, candidates...)
+	random.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	var lowestLarger *candidate
+	var applicable []*candidate
+	var totalLower int64
+	for _, c := range shuffled {
+		switch {
+		case c.effective == target:
+			return []*candidate{c}
+		case c.effective < target+minChange:
+			applicable = append(applicable, c)
+			totalLower += c.effective
+		case lowestLarger == nil || c.effective < lowestLarger.effective:
+			lowestLarger = c
+		}
+	}
+
+	if totalLower == target {
+		return applicable
+	}
+	if totalLower < target {
+		if lowestLarger == nil {
+			return nil
+		}
+		return []*candidate{lowestLarger}
+	}
+
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return applicable[i].effective > applicable[j].effective
+	})
+	best, bestValue := approximateBestSubset(random, applicable, totalLower, target)
+	if bestValue != target && totalLower >= target+minChange {
+		best, bestValue = approximateBestSubset(random, applicable, totalLower, target+minChange)
+	}
+	if lowestLarger != nil && (bestValue != target && bestValue < target+minChange || lowestLarger.effective <= bestValue) {
+		return []*candidate{lowestLarger}
+	}
+
+	var selected []*candidate
+	for index, included := range best {
+		if included {
+			selected = append(selected, applicable[index])
+		}
+	}
+	return selected
+}
+
+// approximateBestSubset draws random subsets, completed in order until they reach `target`, and keeps the smallest
+func approximateBestSubset(random *rand.Rand, candidates []*candidate, total int64, target int64) ([]bool, int64) {
+	best := make([]bool, len(candidates))
+	for index := range best {
+		best[index] = true
+	}
+	bestValue := total
+
+	for iteration := 0; iteration < KNAPSACK_ITERATIONS && bestValue != target; iteration++ {
+		included := make([]bool, len(candidates))
+		var value int64
+		reached := false
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for index, c := range candidates {
+				if pass == 0 && random.Intn(2) == 0 || pass == 1 && included[index] {
+					continue
+				}
+				value += c.effective
+				included[index] = true
+				if value >= target {
+					reached = true
+					if value < bestValue {
+						bestValue = value
+						copy(best, included)
+					}
+					value -= c.effective
+					included[index] = false
+				}
+			}
+		}
+	}
+	return best, bestValue
+}
+
+// largestFirst adds the largest candidates until they reach `target`
+func largestFirst(candidates []*candidate, target int64) []*candidate {
+	var value int64
+	for index, c := range candidates {
+		value += c.effective
+		if value >= target {
+			return candidates[:index+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+)
+
+type Algorithm string
+
+const (
+	Algorithm_BnB          Algorithm = "bnb"
+	Algorithm_Knapsack     Algorithm = "knapsack"
+	Algorithm_LargestFirst Algorithm = "largest-first"
+	// Algorithm_Manual is reported by Fund, the inputs were chosen by the caller
+	Algorithm_Manual Algorithm = "manual"
+
+	// DUST_RELAY_FEE_RATE is bitcoind's default -dustrelayfee, in sat/vB
+	DUST_RELAY_FEE_RATE = 3
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Params describes the transaction to fund: the payment outputs and possibly a change output
+type Params struct {
+	Target     int64   // paid amount, in satoshis
+	FeeRate    float64 // in sat/vB
+	OutputType mongo.ScriptType
+	// OutputScripts are the scripts of the payment outputs when there are several, OutputType is then ignored
+	OutputScripts [][]byte
+	ChangeType    mongo.ScriptType
+	// Fallback runs when no changeless solution exists, knapsack or largest-first
+	Fallback    Algorithm
+	ExcludeDust bool
+	// ExcludeImmature leaves out the coinbase outputs which can't be spent in a block at SpendHeight
+	ExcludeImmature bool
+	SpendHeight     int
+}
+
+// Selection is a funded transaction
+type Selection struct {
+	Algorithm Algorithm     `json:"algorithm"`
+	Inputs    []*mongo.UTXO `json:"inputs"`
+	Vsize     int64         `json:"vsize"`
+	Fee       int64         `json:"fee"`
+	Change    int64         `json:"change"` // 0 when the transaction has no change output
+}
+
+// candidate is a spendable output with the fee of spending it deducted
+type candidate struct {
+	utxo      *mongo.UTXO
+	weight    int64
+	effective int64
+}
+
+func (p *Params) Validate() error {
+	if p.Target <= 0 {
+		return errors.New("target must be positive")
+	}
+	if p.FeeRate <= 0 || math.IsInf(p.FeeRate, 0) || math.IsNaN(p.FeeRate) {
+		return errors.New("fee rate must be positive")
+	}
+	if _, ok := p.OutputType.OutputWeight(); !ok && len(p.OutputScripts) == 0 {
+		return fmt.Errorf("unsupported output type %q", p.OutputType)
+	}
+	if _, ok := p.ChangeType.OutputWeight(); !ok {
+		return fmt.Errorf("unsupported change type %q", p.ChangeType)
+	}
+	switch p.Fallback {
+	case Algorithm_Knapsack, Algorithm_LargestFirst:
+	default:
+		return fmt.Errorf("unknown fallback %q", p.Fallback)
+	}
+	if p.ExcludeImmature && p.SpendHeight <= 0 {
+		return errors.New("excluding immature outputs needs the spend height")
+	}
+	return nil
+}
+
+// Select picks inputs among `utxos` with branch-and-bound, which looks for a selection without change, and falls
+// back to knapsack or largest-first. Outputs whose spending size is unknown (bare multisig, non-standard) or which
+// cost more to spend than they're worth are never selected
+func Select(utxos []*mongo.UTXO, params *Params) (*Selection, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	var candidates []*candidate
+	var available int64
+	for _, utxo := range utxos {
+		weight, ok := inputWeight(utxo)
+		if !ok {
+			continue
+		}
+		if !utxo.Type.SpendsWithWitness() {
+			// the empty witness of a legacy input in a segwit transaction
+			weight++
+		}
+		if params.ExcludeDust && utxo.Amount < DustThreshold(utxo.Type, 8+1+int64(len(utxo.Script)/2)) {
+			continue
+		}
+		if params.ExcludeImmature && utxo.IsImmature(params.SpendHeight) {
+			continue
+		}
+		effective := utxo.Amount - feeFor(weight, params.FeeRate)
+		if effective <= 0 {
+			continue
+		}
+		candidates = append(candidates, &candidate{utxo: utxo, weight: weight, effective: effective})
+		available += effective
+	}
+
+	outputWeight := params.outputWeight()
+	changeWeight, _ := params.ChangeType.OutputWeight()
+	baseWeight := int64(mongo.TX_OVERHEAD_WEIGHT+mongo.SEGWIT_MARKER_WEIGHT) + outputWeight
+	target := params.Target + feeFor(baseWeight, params.FeeRate)
+	if available < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	// change costs its output now and its input later, a changeless selection may waste up to that much
+	costOfChange := feeFor(changeWeight, params.FeeRate)
+	if changeInputWeight, ok := params.ChangeType.InputWeight(); ok {
+		costOfChange += feeFor(changeInputWeight, params.FeeRate)
+	}
+	minChange := DustThreshold(params.ChangeType, changeWeight/mongo.WITNESS_SCALE_FACTOR)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].effective > candidates[j].effective
+	})
+
+	algorithm := Algorithm_BnB
+	selected := branchAndBound(candidates, target, costOfChange)
+	if selected == nil {
+		algorithm = params.Fallback
+		withChange := target + feeFor(changeWeight, params.FeeRate)
+		if algorithm == Algorithm_LargestFirst {
+			selected = largestFirst(candidates, withChange+minChange)
+			if selected == nil {
+				selected = largestFirst(candidates, target)
+			}
+		} else {
+			selected = knapsack(candidates, withChange, minChange)
+		}
+	}
+	if selected == nil {
+		return nil, ErrInsufficientFunds
+	}
+	return finalize(algorithm, selected, params, outputWeight, changeWeight, minChange)
+}
+
+// Fund spends exactly `utxos`, adding change when it's worth an output and leaving anything below that to the fee.
+// The fallback and dust options of `params` don't apply
+func Fund(utxos []*mongo.UTXO, params *Params) (*Selection, error) {
+	if params.Fallback == "" {
+		withFallback := *params
+		withFallback.Fallback = Algorithm_Knapsack
+		params = &withFallback
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	var selected []*candidate
+	for _, utxo := range utxos {
+		weight, ok := inputWeight(utxo)
+		if !ok {
+			return nil, fmt.Errorf("the size of an input spending %s:%d can't be estimated", utxo.TxID, utxo.Vout)
+		}
+		if !utxo.Type.SpendsWithWitness() {
+			weight++
+		}
+		selected = append(selected, &candidate{utxo: utxo, weight: weight})
+	}
+
+	changeWeight, _ := params.ChangeType.OutputWeight()
+	minChange := DustThreshold(params.ChangeType, changeWeight/mongo.WITNESS_SCALE_FACTOR)
+	return finalize(Algorithm_Manual, selected, params, params.outputWeight(), changeWeight, minChange)
+}
+
+// finalize computes the exact fee of the transaction and whether the remainder is worth a change output
+func finalize(algorithm Algorithm, selected []*candidate, params *Params, outputWeight int64, changeWeight int64, minChange int64) (*Selection, error) {
+	weight := int64(mongo.TX_OVERHEAD_WEIGHT) + outputWeight
+	var amount int64
+	segwit := false
+	for _, c := range selected {
+		weight += c.weight
+		amount += c.utxo.Amount
+		segwit = segwit || c.utxo.Type.SpendsWithWitness()
+	}
+	if segwit {
+		weight += mongo.SEGWIT_MARKER_WEIGHT
+	} else {
+		// the empty witnesses counted in the candidates' weight aren't serialized
+		weight -= int64(len(selected))
+	}
+
+	selection := &Selection{Algorithm: algorithm}
+	for _, c := range selected {
+		selection.Inputs = append(selection.Inputs, c.utxo)
+	}
+	if change := amount - params.Target - feeFor(weight+changeWeight, params.FeeRate); change >= minChange {
+		weight += changeWeight
+		selection.Change = change
+	}
+	selection.Fee = amount - params.Target - selection.Change
+	if selection.Fee < feeFor(weight, params.FeeRate) {
+		return nil, ErrInsufficientFunds
+	}
+	selection.Vsize = vsize(weight)
+	return selection, nil
+}
+
+// inputWeight is the weight of an input spending `utxo`, from its stored size, which spends of p2sh and p2wsh
+// scripts refine, or from its type for outputs indexed without a size
+func inputWeight(utxo *mongo.UTXO) (int64, bool) {
+	weight, ok := utxo.Type.InputWeight()
+	if utxo.Size > 0 && (!ok || utxo.Size != utxo.Type.EstimatedInputSize()) {
+		// a size is rounded up to whole vbytes, the type's weight is exact when the size is only its estimate
+		return utxo.Size * mongo.WITNESS_SCALE_FACTOR, true
+	}
+	return weight, ok
+}
+
+func (p *Params) outputWeight() int64 {
+	if len(p.OutputScripts) == 0 {
+		weight, _ := p.OutputType.OutputWeight()
+		return weight
+	}
+
+	var weight int64
+	for _, script := range p.OutputScripts {
+		size := int64(8 + 1 + len(script))
+		if len(script) >= 0xfd {
+			size += 2
+		}
+		weight += size * mongo.WITNESS_SCALE_FACTOR
+	}
+	if len(p.OutputScripts) >= 0xfd {
+		// the output count takes 3 bytes instead of 1
+		weight += 2 * mongo.WITNESS_SCALE_FACTOR
+	}
+	return weight
+}
+
+// DustThreshold is the amount under which bitcoind considers an output of `outputSize` serialized bytes as dust
+func DustThreshold(scriptType mongo.ScriptType, outputSize int64) int64 {
+	spendSize := int64(32 + 4 + 1 + 107 + 4)
+	if scriptType.IsWitnessProgram() {
+		spendSize = 32 + 4 + 1 + 107/mongo.WITNESS_SCALE_FACTOR + 4
+	}
+	return (outputSize + spendSize) * DUST_RELAY_FEE_RATE
+}
+
+func feeFor(weight int64, feeRate float64) int64 {
+	return int64(math.Ceil(float64(vsize(weight)) * feeRate))
+}
+
+func vsize(weight int64) int64 {
+	return (weight + mongo.WITNESS_SCALE_FACTOR - 1) / mongo.WITNESS_SCALE_FACTOR
+}
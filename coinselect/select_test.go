@@ -0,0 +1,295 @@
+package coinselect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+)
+
+// at 1 sat/vB a p2wkh payment costs 42 sats of overhead and output, each p2wkh input 68, change 31 and spending it 68
+const TARGET = 100000
+
+// P2WKH_SCRIPT is the script of a p2wkh output, in hex
+const P2WKH_SCRIPT = "00140000000000000000000000000000000000000000"
+
+func p2wkh(txid string, amount int64) *mongo.UTXO {
+	return &mongo.UTXO{TxID: txid, Amount: amount, Type: mongo.ScriptType_P2WKH, Script: P2WKH_SCRIPT}
+}
+
+func newParams(fallback Algorithm) *Params {
+	return &Params{
+		Target:     TARGET,
+		FeeRate:    1,
+		OutputType: mongo.ScriptType_P2WKH,
+		ChangeType: mongo.ScriptType_P2WKH,
+		Fallback:   fallback,
+	}
+}
+
+func txids(utxos []*mongo.UTXO) []string {
+	var ids []string
+	for _, utxo := range utxos {
+		ids = append(ids, utxo.TxID)
+	}
+	return ids
+}
+
+func sameTxids(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSelect(t *testing.T) {
+	p2wsh := &mongo.UTXO{TxID: "p2wsh", Amount: TARGET + 42 + 200, Type: mongo.ScriptType_P2WSH, Size: 200}
+	multisig := &mongo.UTXO{TxID: "multisig", Amount: 1000000, Type: mongo.ScriptType_Multisig}
+	withDust := func(params *Params) *Params {
+		params.ExcludeDust = true
+		return params
+	}
+	// spending in the block at height 1000
+	const spendHeight = 1000
+	withoutImmature := func(params *Params) *Params {
+		params.ExcludeImmature = true
+		params.SpendHeight = spendHeight
+		return params
+	}
+	exactAt := func(txid string, height int, coinbase bool) *mongo.UTXO {
+		utxo := p2wkh(txid, TARGET+42+68)
+		utxo.Height, utxo.Coinbase = height, coinbase
+		return utxo
+	}
+
+	tests := []struct {
+		name      string
+		utxos     []*mongo.UTXO
+		params    *Params
+		err       error
+		algorithm Algorithm
+		inputs    []string
+		fee       int64
+		change    int64
+		vsize     int64
+	}{
+		{
+			name:      "exact match without change",
+			utxos:     []*mongo.UTXO{p2wkh("large", 5000000), p2wkh("exact", TARGET+42+68), p2wkh("small", 20000)},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"exact"},
+			fee:       110,
+			vsize:     110,
+		},
+		{
+			name:      "exact match including dust",
+			utxos:     []*mongo.UTXO{p2wkh("short", TARGET+42+68-50), p2wkh("dust", 150)},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"short", "dust"},
+			fee:       210,
+			vsize:     178,
+		},
+		{
+			name:   "dust excluded",
+			utxos:  []*mongo.UTXO{p2wkh("short", TARGET+42+68-50), p2wkh("dust", 150)},
+			params: withDust(newParams(Algorithm_Knapsack)),
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:      "knapsack fallback with change",
+			utxos:     []*mongo.UTXO{p2wkh("large", 1000000)},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_Knapsack,
+			inputs:    []string{"large"},
+			fee:       141,
+			change:    1000000 - TARGET - 141,
+			vsize:     141,
+		},
+		{
+			name:      "change below dust goes to the fee",
+			utxos:     []*mongo.UTXO{p2wkh("over", TARGET+42+68+200)},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_Knapsack,
+			inputs:    []string{"over"},
+			fee:       310,
+			vsize:     110,
+		},
+		{
+			name:      "largest-first fallback",
+			utxos:     []*mongo.UTXO{p2wkh("smaller", 50000), p2wkh("larger", 60000)},
+			params:    newParams(Algorithm_LargestFirst),
+			algorithm: Algorithm_LargestFirst,
+			inputs:    []string{"larger", "smaller"},
+			fee:       209,
+			change:    110000 - TARGET - 209,
+			vsize:     209,
+		},
+		{
+			name:      "stored size weighs the input",
+			utxos:     []*mongo.UTXO{p2wsh},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"p2wsh"},
+			fee:       242,
+			vsize:     242,
+		},
+		{
+			name:      "coinbase output reaching maturity",
+			utxos:     []*mongo.UTXO{exactAt("mature", spendHeight-mongo.COINBASE_MATURITY, true)},
+			params:    withoutImmature(newParams(Algorithm_Knapsack)),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"mature"},
+			fee:       110,
+			vsize:     110,
+		},
+		{
+			name:   "coinbase output one block short of maturity",
+			utxos:  []*mongo.UTXO{exactAt("immature", spendHeight-mongo.COINBASE_MATURITY+1, true)},
+			params: withoutImmature(newParams(Algorithm_Knapsack)),
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:      "regular output of the tip",
+			utxos:     []*mongo.UTXO{exactAt("tip", spendHeight-1, false)},
+			params:    withoutImmature(newParams(Algorithm_Knapsack)),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"tip"},
+			fee:       110,
+			vsize:     110,
+		},
+		{
+			name:      "immature coinbase output selected unless excluded",
+			utxos:     []*mongo.UTXO{exactAt("immature", spendHeight-1, true)},
+			params:    newParams(Algorithm_Knapsack),
+			algorithm: Algorithm_BnB,
+			inputs:    []string{"immature"},
+			fee:       110,
+			vsize:     110,
+		},
+		{
+			name:   "insufficient funds",
+			utxos:  []*mongo.UTXO{p2wkh("small", 50000)},
+			params: newParams(Algorithm_Knapsack),
+			err:    ErrInsufficientFunds,
+		},
+		{
+			name:   "outputs of unknown spending size are never selected",
+			utxos:  []*mongo.UTXO{multisig},
+			params: newParams(Algorithm_Knapsack),
+			err:    ErrInsufficientFunds,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selection, err := Select(test.utxos, test.params)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected error %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if selection.Algorithm != test.algorithm {
+				t.Errorf("expected algorithm %s, got %s", test.algorithm, selection.Algorithm)
+			}
+			if inputs := txids(selection.Inputs); !sameTxids(inputs, test.inputs) {
+				t.Errorf("expected inputs %v, got %v", test.inputs, inputs)
+			}
+			if selection.Fee != test.fee || selection.Change != test.change || selection.Vsize != test.vsize {
+				t.Errorf("expected fee %d, change %d and vsize %d, got %d, %d and %d",
+					test.fee, test.change, test.vsize, selection.Fee, selection.Change, selection.Vsize)
+			}
+		})
+	}
+}
+
+func TestFund(t *testing.T) {
+	tests := []struct {
+		name   string
+		utxos  []*mongo.UTXO
+		err    error
+		fee    int64
+		change int64
+	}{
+		{
+			name:   "change output",
+			utxos:  []*mongo.UTXO{p2wkh("large", 1000000)},
+			fee:    141,
+			change: 1000000 - TARGET - 141,
+		},
+		{
+			name:  "change below dust goes to the fee",
+			utxos: []*mongo.UTXO{p2wkh("over", TARGET+42+68+200)},
+			fee:   310,
+		},
+		{
+			name:  "insufficient funds",
+			utxos: []*mongo.UTXO{p2wkh("short", TARGET+100)},
+			err:   ErrInsufficientFunds,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := newParams("")
+			selection, err := Fund(test.utxos, params)
+			if params.Fallback != "" {
+				t.Errorf("the caller's params were modified")
+			}
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected error %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if selection.Algorithm != Algorithm_Manual || len(selection.Inputs) != len(test.utxos) {
+				t.Errorf("expected all %d inputs to be spent as given, got %s with %v",
+					len(test.utxos), selection.Algorithm, txids(selection.Inputs))
+			}
+			if selection.Fee != test.fee || selection.Change != test.change {
+				t.Errorf("expected fee %d and change %d, got %d and %d", test.fee, test.change, selection.Fee, selection.Change)
+			}
+		})
+	}
+
+	multisig := &mongo.UTXO{TxID: "multisig", Amount: 1000000, Type: mongo.ScriptType_Multisig}
+	if _, err := Fund([]*mongo.UTXO{multisig}, newParams("")); err == nil || errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected an error about the input size, got %v", err)
+	}
+}
+
+func TestInputWeight(t *testing.T) {
+	p2wkhWeight, _ := mongo.ScriptType_P2WKH.InputWeight()
+	tests := []struct {
+		name   string
+		utxo   *mongo.UTXO
+		weight int64
+		ok     bool
+	}{
+		{"type weight without size", &mongo.UTXO{Type: mongo.ScriptType_P2WKH}, p2wkhWeight, true},
+		{"type weight when the size is its estimate", &mongo.UTXO{Type: mongo.ScriptType_P2WKH, Size: 68}, p2wkhWeight, true},
+		{"stored size of a p2sh spend", &mongo.UTXO{Type: mongo.ScriptType_P2SH, Size: 297}, 297 * mongo.WITNESS_SCALE_FACTOR, true},
+		{"stored size of a p2wsh output", &mongo.UTXO{Type: mongo.ScriptType_P2WSH, Size: 105}, 105 * mongo.WITNESS_SCALE_FACTOR, true},
+		{"p2wsh without size", &mongo.UTXO{Type: mongo.ScriptType_P2WSH}, 0, false},
+		{"bare multisig", &mongo.UTXO{Type: mongo.ScriptType_Multisig}, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			weight, ok := inputWeight(test.utxo)
+			if weight != test.weight || ok != test.ok {
+				t.Errorf("expected %d, %t, got %d, %t", test.weight, test.ok, weight, ok)
+			}
+		})
+	}
+}
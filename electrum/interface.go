@@ -0,0 +1,12 @@
+package electrum
+
+import (
+	"context"
+	"net"
+)
+
+//go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=electrum
+type Interface interface {
+	Start(ctx context.Context, listeners ...net.Listener)
+	Wait()
+}
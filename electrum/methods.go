@@ -0,0 +1,212 @@
+package electrum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+)
+
+const (
+	METHOD_SERVER_VERSION         = "server.version"
+	METHOD_SERVER_PING            = "server.ping"
+	METHOD_HEADERS_SUBSCRIBE      = "blockchain.headers.subscribe"
+	METHOD_BLOCK_HEADER           = "blockchain.block.header"
+	METHOD_SCRIPTHASH_LISTUNSPENT = "blockchain.scripthash.listunspent"
+	METHOD_SCRIPTHASH_GET_BALANCE = "blockchain.scripthash.get_balance"
+	METHOD_SCRIPTHASH_SUBSCRIBE   = "blockchain.scripthash.subscribe"
+	METHOD_SCRIPTHASH_UNSUBSCRIBE = "blockchain.scripthash.unsubscribe"
+	METHOD_TRANSACTION_BROADCAST  = "blockchain.transaction.broadcast"
+)
+
+func (s server) dispatch(ctx context.Context, session *session, request *Request) (interface{}, *Error) {
+	var params []json.RawMessage
+	if len(request.Params) > 0 && string(request.Params) != "null" {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, newError(CODE_INVALID_PARAMS, "params must be an array")
+		}
+	}
+
+	switch request.Method {
+	case METHOD_SERVER_VERSION:
+		return []string{SERVER_VERSION, PROTOCOL_VERSION}, nil
+	case METHOD_SERVER_PING:
+		return nil, nil
+	case METHOD_HEADERS_SUBSCRIBE:
+		header, err := s.tipHeader(ctx)
+		if err != nil {
+			return nil, daemonError(err)
+		}
+		session.subscribeHeaders()
+		return header, nil
+	case METHOD_BLOCK_HEADER:
+		var height, checkpointHeight int
+		if err := parseParams(params, 1, &height, &checkpointHeight); err != nil {
+			return nil, err
+		}
+		if checkpointHeight != 0 {
+			return nil, newError(CODE_BAD_REQUEST, "cp_height is not supported")
+		}
+		return s.blockHeader(ctx, height)
+	case METHOD_SCRIPTHASH_LISTUNSPENT:
+		scriptHash, err := scriptHashParam(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.listUnspent(ctx, scriptHash)
+	case METHOD_SCRIPTHASH_GET_BALANCE:
+		scriptHash, err := scriptHashParam(params)
+		if err != nil {
+			return nil, err
+		}
+		balance, dbErr := s.mongoServer.GetScriptHashBalance(ctx, scriptHash)
+		if dbErr != nil {
+			return nil, newError(CODE_INTERNAL_ERROR, dbErr.Error())
+		}
+		return balance, nil
+	case METHOD_SCRIPTHASH_SUBSCRIBE:
+		scriptHash, err := scriptHashParam(params)
+		if err != nil {
+			return nil, err
+		}
+		status, dbErr := s.scriptHashStatus(ctx, scriptHash)
+		if dbErr != nil {
+			return nil, newError(CODE_INTERNAL_ERROR, dbErr.Error())
+		}
+		if err := session.subscribe(scriptHash, status); err != nil {
+			return nil, err
+		}
+		return status, nil
+	case METHOD_SCRIPTHASH_UNSUBSCRIBE:
+		scriptHash, err := scriptHashParam(params)
+		if err != nil {
+			return nil, err
+		}
+		return session.unsubscribe(scriptHash), nil
+	case METHOD_TRANSACTION_BROADCAST:
+		var rawTx string
+		if err := parseParams(params, 1, &rawTx); err != nil {
+			return nil, err
+		}
+		txid, err := s.fullnode.SendRawTransaction(ctx, rawTx)
+		if err != nil {
+			var rpcErr *fullnode.RPCError
+			if errors.As(err, &rpcErr) {
+				// the node rejected the transaction, its reason is what the wallet shows
+				return nil, newError(CODE_BAD_REQUEST, rpcErr.Message)
+			}
+			return nil, daemonError(err)
+		}
+		if s.mempool != nil {
+			// the transaction is out, failing to record it only delays its spends until the next mempool poll
+			if err := s.mempool.Track(ctx, txid); err != nil {
+				log.Println("[warning] failed to record broadcast transaction with error: ", err.Error())
+			}
+		}
+		return txid, nil
+	}
+	return nil, newError(CODE_METHOD_NOT_FOUND, "unknown method %q", request.Method)
+}
+
+// parseParams decodes the positional `params` into `targets`, the first `required` of them must be present
+func parseParams(params []json.RawMessage, required int, targets ...interface{}) *Error {
+	if len(params) < required {
+		return newError(CODE_INVALID_PARAMS, "expected at least %d params, got %d", required, len(params))
+	}
+	if len(params) > len(targets) {
+		return newError(CODE_INVALID_PARAMS, "expected at most %d params, got %d", len(targets), len(params))
+	}
+	for index, param := range params {
+		if err := json.Unmarshal(param, targets[index]); err != nil {
+			return newError(CODE_INVALID_PARAMS, "invalid param %d: %s", index, err.Error())
+		}
+	}
+	return nil
+}
+
+func scriptHashParam(params []json.RawMessage) (string, *Error) {
+	var scriptHash string
+	if err := parseParams(params, 1, &scriptHash); err != nil {
+		return "", err
+	}
+	if !mongo.ScriptHashPattern.MatchString(scriptHash) {
+		return "", newError(CODE_BAD_REQUEST, "%q is not a valid scripthash", scriptHash)
+	}
+	return scriptHash, nil
+}
+
+func daemonError(err error) *Error {
+	return newError(CODE_DAEMON_ERROR, "daemon error: %s", err.Error())
+}
+
+// tipHeader returns the header of the last indexed block, which is what the UTXO answers reflect
+func (s server) tipHeader(ctx context.Context) (*Header, error) {
+	status := s.syncer.Status()
+	if status.IndexedHeight < 0 || status.IndexedHash == "" {
+		return nil, errors.New("no block has been indexed yet")
+	}
+	headerHex, err := s.fullnode.GetBlockHeaderHex(ctx, status.IndexedHash)
+	if err != nil {
+		return nil, err
+	}
+	return &Header{Height: status.IndexedHeight, Hex: headerHex}, nil
+}
+
+func (s server) blockHeader(ctx context.Context, height int) (string, *Error) {
+	if indexedHeight := s.syncer.Status().IndexedHeight; height < 0 || height > indexedHeight {
+		return "", newError(CODE_BAD_REQUEST, "height %d out of range", height)
+	}
+
+	var hash string
+	header, err := s.mongoServer.GetBlockHeader(ctx, height)
+	switch {
+	case err == nil:
+		hash = header.Hash
+	case errors.Is(err, mongo.ErrNotFound):
+		// blocks synced before their hashes were recorded
+		if hash, err = s.fullnode.GetBlockHash(ctx, height); err != nil {
+			return "", daemonError(err)
+		}
+	default:
+		return "", newError(CODE_INTERNAL_ERROR, err.Error())
+	}
+
+	headerHex, err := s.fullnode.GetBlockHeaderHex(ctx, hash)
+	if err != nil {
+		return "", daemonError(err)
+	}
+	return headerHex, nil
+}
+
+func (s server) listUnspent(ctx context.Context, scriptHash string) ([]*mongo.UnspentOutput, *Error) {
+	utxos, err := s.mongoServer.ListUnspentByScriptHash(ctx, scriptHash)
+	if err != nil {
+		return nil, newError(CODE_INTERNAL_ERROR, err.Error())
+	}
+	return mongo.NewUnspentOutputs(utxos), nil
+}
+
+// scriptHashStatus summarizes the unspent outputs of `scriptHash`, nil when there are none. Unlike ElectrumX
+// it's derived from the unspent set rather than the history, which isn't indexed, so clients must treat it as opaque
+func (s server) scriptHashStatus(ctx context.Context, scriptHash string) (*string, error) {
+	utxos, err := s.mongoServer.ListUnspentByScriptHash(ctx, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) == 0 {
+		return nil, nil
+	}
+
+	hasher := sha256.New()
+	for _, utxo := range utxos {
+		fmt.Fprintf(hasher, "%s:%d:%d:", utxo.TxID, utxo.Vout, utxo.ElectrumHeight())
+	}
+	status := hex.EncodeToString(hasher.Sum(nil))
+	return &status, nil
+}
@@ -0,0 +1,230 @@
+package electrum
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mempool"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
+)
+
+const (
+	PROTOCOL_VERSION = "1.4"
+	SERVER_VERSION   = "bitcoin-utxo-ms " + synchronizer.VERSION
+
+	// NOTIFY_INTERVAL coalesces notifications, e.g. while catching up many blocks a second are applied
+	NOTIFY_INTERVAL = 2 * time.Second
+
+	// ENV_ELECTRUM_MAX_SESSIONS caps the concurrent sessions, connections above it are closed right away
+	ENV_ELECTRUM_MAX_SESSIONS = "ELECTRUM_MAX_SESSIONS"
+	DEFAULT_MAX_SESSIONS      = 1000
+)
+
+// server answers Electrum protocol requests from the UTXO collection and bitcoind
+type server struct {
+	mongoServer mongo.Interface
+	fullnode    fullnode.Interface
+	syncer      synchronizer.Interface
+	mempool     mempool.Interface // nil without mempool tracking
+	sessions    *sessions
+	running     *sync.WaitGroup
+	// slots holds a token per open session
+	slots chan struct{}
+
+	tipChanged     chan struct{}
+	mempoolChanged chan struct{}
+}
+
+type sessions struct {
+	mu  sync.Mutex
+	set map[*session]bool
+}
+
+// New creates the server, `w` is optional, it triggers notifications when the mempool changes and records broadcast
+// transactions
+func New(m mongo.Interface, f fullnode.Interface, syncer synchronizer.Interface, w mempool.Interface) Interface {
+	s := &server{
+		mongoServer:    m,
+		fullnode:       f,
+		syncer:         syncer,
+		mempool:        w,
+		sessions:       &sessions{set: map[*session]bool{}},
+		running:        &sync.WaitGroup{},
+		slots:          make(chan struct{}, fullnode.IntFromEnv(ENV_ELECTRUM_MAX_SESSIONS, DEFAULT_MAX_SESSIONS)),
+		tipChanged:     make(chan struct{}, 1),
+		mempoolChanged: make(chan struct{}, 1),
+	}
+
+	syncer.OnTipChanged(func(height int, hash string) {
+		signal(s.tipChanged)
+	})
+	if w != nil {
+		w.OnChange(func() {
+			signal(s.mempoolChanged)
+		})
+	}
+	return s
+}
+
+// signal never blocks, a pending signal already covers the new change
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Start serves `listeners` and sends the subscription notifications in the background until ctx is done
+func (s server) Start(ctx context.Context, listeners ...net.Listener) {
+	// counted before any goroutine runs, so Wait can't return before they do
+	s.running.Add(1 + len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			defer s.running.Done()
+			if err := s.serve(ctx, listener); err != nil {
+				log.Println("[error] electrum server stopped with error: ", err)
+			}
+		}(listener)
+	}
+
+	go func() {
+		defer s.running.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.tipChanged:
+				s.notifyHeaders(ctx)
+				s.notifyScriptHashes(ctx)
+			case <-s.mempoolChanged:
+				s.notifyScriptHashes(ctx)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(NOTIFY_INTERVAL):
+			}
+		}
+	}()
+}
+
+// serve accepts connections on `listener` until ctx is done, each one is a session of newline delimited JSON-RPC
+func (s server) serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	log.Println("[debug] electrum server listening on ", listener.Addr().String())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			log.Println("[warning] closing electrum connection from ", conn.RemoteAddr().String(), ", too many sessions")
+			conn.Close()
+			continue
+		}
+		s.running.Add(1)
+		go s.handle(ctx, conn)
+	}
+}
+
+// Wait blocks until the notifications, the listeners and the sessions have stopped, once ctx is done
+func (s server) Wait() {
+	s.running.Wait()
+}
+
+func (s server) handle(ctx context.Context, conn net.Conn) {
+	defer s.running.Done()
+	defer func() { <-s.slots }()
+	session := newSession(conn)
+	s.sessions.mu.Lock()
+	s.sessions.set[session] = true
+	s.sessions.mu.Unlock()
+
+	defer func() {
+		s.sessions.mu.Lock()
+		delete(s.sessions.set, session)
+		s.sessions.mu.Unlock()
+		session.close()
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.close()
+		case <-done:
+		}
+	}()
+
+	session.serve(ctx, s.dispatch)
+}
+
+func (s server) snapshot() []*session {
+	s.sessions.mu.Lock()
+	defer s.sessions.mu.Unlock()
+
+	all := make([]*session, 0, len(s.sessions.set))
+	for session := range s.sessions.set {
+		all = append(all, session)
+	}
+	return all
+}
+
+func (s server) notifyHeaders(ctx context.Context) {
+	var header *Header
+	for _, session := range s.snapshot() {
+		if !session.subscribedToHeaders() {
+			continue
+		}
+		if header == nil {
+			var err error
+			if header, err = s.tipHeader(ctx); err != nil {
+				log.Println("[warning] failed to get tip header for notifications with error: ", err.Error())
+				return
+			}
+		}
+		session.notify(METHOD_HEADERS_SUBSCRIBE, header)
+	}
+}
+
+// notifyScriptHashes recomputes the status of every subscribed scripthash and notifies the ones which changed
+func (s server) notifyScriptHashes(ctx context.Context) {
+	statuses := map[string]*string{}
+	for _, session := range s.snapshot() {
+		for _, scriptHash := range session.scriptHashes() {
+			status, ok := statuses[scriptHash]
+			if !ok {
+				var err error
+				if status, err = s.scriptHashStatus(ctx, scriptHash); err != nil {
+					log.Println("[warning] failed to get scripthash status for notifications with error: ", err.Error())
+					return
+				}
+				statuses[scriptHash] = status
+			}
+			if session.updateStatus(scriptHash, status) {
+				session.notify(METHOD_SCRIPTHASH_SUBSCRIBE, scriptHash, status)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// MAX_REQUEST_SIZE fits the broadcast of a transaction of the maximum standard weight, hex encoded
+	MAX_REQUEST_SIZE = 4 << 20
+	IDLE_TIMEOUT     = 10 * time.Minute
+	WRITE_TIMEOUT    = 30 * time.Second
+	// MAX_SUBSCRIPTIONS bounds the scripthashes a session watches, every one is queried on each change
+	MAX_SUBSCRIPTIONS = 1000
+)
+
+type dispatcher func(ctx context.Context, session *session, request *Request) (interface{}, *Error)
+
+// session is one client connection with its subscriptions
+type session struct {
+	conn      net.Conn
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	headers  bool
+	statuses map[string]*string // subscribed scripthash to the last status sent
+}
+
+func newSession(conn net.Conn) *session {
+	return &session{
+		conn:     conn,
+		statuses: map[string]*string{},
+	}
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		s.conn.Close()
+	})
+}
+
+// serve reads requests line by line until the connection fails or stays idle too long
+func (s *session) serve(ctx context.Context, dispatch dispatcher) {
+	scanner := bufio.NewScanner(s.conn)
+	scanner.Buffer(make([]byte, 0, 64<<10), MAX_REQUEST_SIZE)
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(IDLE_TIMEOUT))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				log.Printf("[debug] electrum session %s closed with error: %s\n", s.conn.RemoteAddr(), err.Error())
+			}
+			return
+		}
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if response := s.handleLine(ctx, line, dispatch); response != nil {
+			s.write(response)
+		}
+	}
+}
+
+// handleLine answers a request or a batch of requests, notifications get no answer
+func (s *session) handleLine(ctx context.Context, line []byte, dispatch dispatcher) interface{} {
+	if line[0] != '[' {
+		return s.handleRequest(ctx, line, dispatch)
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return &errorResponse{JsonRpc: "2.0", ID: json.RawMessage("null"), Error: newError(CODE_PARSE_ERROR, err.Error())}
+	}
+	if len(batch) == 0 {
+		return &errorResponse{JsonRpc: "2.0", ID: json.RawMessage("null"), Error: newError(CODE_INVALID_REQUEST, "empty batch")}
+	}
+
+	var responses []interface{}
+	for _, raw := range batch {
+		if response := s.handleRequest(ctx, raw, dispatch); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+func (s *session) handleRequest(ctx context.Context, raw []byte, dispatch dispatcher) interface{} {
+	request := &Request{}
+	if err := json.Unmarshal(raw, request); err != nil {
+		return &errorResponse{JsonRpc: "2.0", ID: json.RawMessage("null"), Error: newError(CODE_PARSE_ERROR, err.Error())}
+	}
+	if request.Method == "" {
+		return &errorResponse{JsonRpc: "2.0", ID: idOrNull(request.ID), Error: newError(CODE_INVALID_REQUEST, "missing method")}
+	}
+
+	result, rpcErr := dispatch(ctx, s, request)
+	if len(request.ID) == 0 {
+		// a notification from the client
+		return nil
+	}
+	if rpcErr != nil {
+		return &errorResponse{JsonRpc: "2.0", ID: request.ID, Error: rpcErr}
+	}
+	return &resultResponse{JsonRpc: "2.0", ID: request.ID, Result: result}
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func (s *session) notify(method string, params ...interface{}) {
+	s.write(&Notification{JsonRpc: "2.0", Method: method, Params: params})
+}
+
+// write sends one message, a client which doesn't keep up is disconnected
+func (s *session) write(message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Println("[error] failed to marshal electrum message with error: ", err.Error())
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(WRITE_TIMEOUT))
+	if _, err := s.conn.Write(append(data, '\n')); err != nil {
+		s.close()
+	}
+}
+
+func (s *session) subscribeHeaders() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers = true
+}
+
+func (s *session) subscribedToHeaders() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers
+}
+
+func (s *session) subscribe(scriptHash string, status *string) *Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.statuses[scriptHash]; !ok && len(s.statuses) >= MAX_SUBSCRIPTIONS {
+		return newError(CODE_BAD_REQUEST, "too many subscriptions, the limit is %d", MAX_SUBSCRIPTIONS)
+	}
+	s.statuses[scriptHash] = status
+	return nil
+}
+
+func (s *session) unsubscribe(scriptHash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.statuses[scriptHash]
+	delete(s.statuses, scriptHash)
+	return ok
+}
+
+func (s *session) scriptHashes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scriptHashes := make([]string, 0, len(s.statuses))
+	for scriptHash := range s.statuses {
+		scriptHashes = append(scriptHashes, scriptHash)
+	}
+	return scriptHashes
+}
+
+// updateStatus records `status` and tells whether it differs from the last one sent
+func (s *session) updateStatus(scriptHash string, status *string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.statuses[scriptHash]
+	if !ok || sameStatus(previous, status) {
+		return false
+	}
+	s.statuses[scriptHash] = status
+	return true
+}
+
+func sameStatus(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,8 @@
+package fees
+
+import "context"
+
+//go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=fees
+type Interface interface {
+	Estimate(ctx context.Context) (*Estimates, error)
+}
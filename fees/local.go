@@ -0,0 +1,91 @@
+package fees
+
+import (
+	"context"
+	"sort"
+)
+
+// PERCENTILES of the block space the local estimate reports the fee rate of
+var PERCENTILES = []int{10, 25, 50, 75, 90}
+
+// blockFeeRates are the fee rates paid in a block, kept across blocks so each one is fetched once
+type blockFeeRates struct {
+	hash string
+	txs  []*txFeeRate
+}
+
+type txFeeRate struct {
+	feeRate float64 // in sat/vB
+	vsize   int64
+}
+
+// localEstimate computes the fee rates paid in the blocks up to `height`, weighted by the space they took. It reuses
+// the fee rates of `cached` blocks and returns those of the blocks it covers
+func (s server) localEstimate(ctx context.Context, height int, cached map[int]*blockFeeRates) (*LocalEstimate, map[int]*blockFeeRates, error) {
+	from := height - s.localBlocks + 1
+	if from < 0 {
+		from = 0
+	}
+
+	blocks := make(map[int]*blockFeeRates, s.localBlocks)
+	var txs []*txFeeRate
+	for h := from; h <= height; h++ {
+		hash, err := s.fullnode.GetBlockHash(ctx, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		block, ok := cached[h]
+		if !ok || block.hash != hash {
+			// a block replaced by a reorg is fetched again
+			if block, err = s.blockFeeRates(ctx, hash); err != nil {
+				return nil, nil, err
+			}
+		}
+		blocks[h] = block
+		txs = append(txs, block.txs...)
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].feeRate < txs[j].feeRate
+	})
+	var total int64
+	for _, tx := range txs {
+		total += tx.vsize
+	}
+
+	estimate := &LocalEstimate{Blocks: height - from + 1}
+	var cumulated int64
+	index := 0
+	for _, percentile := range PERCENTILES {
+		for index < len(txs) && cumulated*100 < total*int64(percentile) {
+			cumulated += txs[index].vsize
+			index++
+		}
+		feeRate := 0.0
+		if index > 0 {
+			feeRate = txs[index-1].feeRate
+		}
+		estimate.Percentiles = append(estimate.Percentiles, &Percentile{Percentile: percentile, FeeRate: feeRate})
+	}
+	return estimate, blocks, nil
+}
+
+// blockFeeRates fetches a block with its transactions, bitcoind gives their fee since it keeps undo data
+func (s server) blockFeeRates(ctx context.Context, hash string) (*blockFeeRates, error) {
+	block, err := s.fullnode.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	feeRates := &blockFeeRates{hash: hash}
+	for _, tx := range block.Transactions {
+		if tx == nil || tx.Vsize <= 0 || len(tx.TxIns) == 0 || tx.TxIns[0].Coinbase != "" {
+			continue
+		}
+		feeRates.txs = append(feeRates.txs, &txFeeRate{
+			feeRate: roundFeeRate(float64(tx.Fee) / float64(tx.Vsize)),
+			vsize:   tx.Vsize,
+		})
+	}
+	return feeRates, nil
+}
@@ -0,0 +1,37 @@
+package fees
+
+// Estimates are the fee rates, in sat/vB, suggested at a block height of the node
+type Estimates struct {
+	Height  int            `json:"height"`
+	Targets []*Bucket      `json:"targets"`
+	Mempool *MempoolStats  `json:"mempool"`
+	Local   *LocalEstimate `json:"local,omitempty"` // only with FEE_LOCAL_BLOCKS
+}
+
+// Bucket is bitcoind's estimate for confirming within Target blocks, fee rates are null when it has none and
+// Errors tells why
+type Bucket struct {
+	Target              int      `json:"target"`
+	FeeRate             *float64 `json:"fee_rate"` // economical
+	ConservativeFeeRate *float64 `json:"conservative_fee_rate"`
+	Errors              []string `json:"errors,omitempty"`
+}
+
+type MempoolStats struct {
+	TxCount         int     `json:"tx_count"`
+	Vsize           int64   `json:"vsize"`
+	MinFeeRate      float64 `json:"min_fee_rate"`       // the node's mempool minimum, raised when it's full
+	MinRelayFeeRate float64 `json:"min_relay_fee_rate"` // below which the node doesn't relay at all
+}
+
+// LocalEstimate describes the fee rates paid in the last Blocks blocks
+type LocalEstimate struct {
+	Blocks      int           `json:"blocks"`
+	Percentiles []*Percentile `json:"percentiles"`
+}
+
+// Percentile is the fee rate under which Percentile percent of the block space went
+type Percentile struct {
+	Percentile int     `json:"percentile"`
+	FeeRate    float64 `json:"fee_rate"`
+}
@@ -0,0 +1,181 @@
+package fees
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// ENV_FEE_LOCAL_BLOCKS is the number of recent blocks the local estimate is computed from, 0 (the default) disables it
+	ENV_FEE_LOCAL_BLOCKS = "FEE_LOCAL_BLOCKS"
+	MAX_LOCAL_BLOCKS     = 144
+
+	// REFRESH_TIMEOUT bounds the computation of the estimates, which outlives the request that started it
+	REFRESH_TIMEOUT = time.Minute
+)
+
+// TARGETS are the confirmation targets estimates are given for, bitcoind estimates up to 1008 blocks
+var TARGETS = []int{1, 2, 3, 6, 12, 24, 144, 1008}
+
+// server asks bitcoind for estimates once per block and serves them from memory in between
+type server struct {
+	fullnode    fullnode.Interface
+	localBlocks int
+	cache       *cache
+	// refreshes runs one computation per height, whatever the number of requests waiting for it
+	refreshes *singleflight.Group
+}
+
+type cache struct {
+	mu        sync.Mutex
+	height    int
+	estimates *Estimates
+	blocks    map[int]*blockFeeRates
+}
+
+func New(f fullnode.Interface) Interface {
+	localBlocks := 0
+	if value, err := strconv.Atoi(os.Getenv(ENV_FEE_LOCAL_BLOCKS)); err == nil && value > 0 {
+		localBlocks = value
+		if localBlocks > MAX_LOCAL_BLOCKS {
+			log.Printf("[warning] %s is capped to %d\n", ENV_FEE_LOCAL_BLOCKS, MAX_LOCAL_BLOCKS)
+			localBlocks = MAX_LOCAL_BLOCKS
+		}
+	}
+	return &server{
+		fullnode:    f,
+		localBlocks: localBlocks,
+		cache:       &cache{blocks: map[int]*blockFeeRates{}},
+		refreshes:   &singleflight.Group{},
+	}
+}
+
+// Estimate returns the estimates at the node's tip, computed on the first call after a new block. Concurrent calls
+// wait for the same computation, which isn't cancelled when the caller gives up
+func (s server) Estimate(ctx context.Context) (*Estimates, error) {
+	height, err := s.fullnode.GetBestBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.mu.Lock()
+	if s.cache.estimates != nil && s.cache.height == height {
+		estimates := s.cache.estimates
+		s.cache.mu.Unlock()
+		return estimates, nil
+	}
+	s.cache.mu.Unlock()
+
+	refresh := s.refreshes.DoChan(strconv.Itoa(height), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), REFRESH_TIMEOUT)
+		defer cancel()
+		return s.refresh(ctx, height)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-refresh:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*Estimates), nil
+	}
+}
+
+// refresh computes the estimates at `height` and caches them, unless estimates at a later height were cached first
+func (s server) refresh(ctx context.Context, height int) (*Estimates, error) {
+	s.cache.mu.Lock()
+	// a caller may have missed the cache just before the previous computation at this height filled it
+	if s.cache.estimates != nil && s.cache.height == height {
+		estimates := s.cache.estimates
+		s.cache.mu.Unlock()
+		return estimates, nil
+	}
+	// the map is replaced rather than modified, it can be read without the lock
+	blocks := s.cache.blocks
+	s.cache.mu.Unlock()
+
+	economical, err := s.fullnode.EstimateSmartFees(ctx, TARGETS, fullnode.EstimateModeEconomical)
+	if err != nil {
+		return nil, err
+	}
+	conservative, err := s.fullnode.EstimateSmartFees(ctx, TARGETS, fullnode.EstimateModeConservative)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.fullnode.GetMempoolInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	estimates := &Estimates{
+		Height: height,
+		Mempool: &MempoolStats{
+			TxCount:         info.Size,
+			Vsize:           info.Bytes,
+			MinFeeRate:      perVbyte(info.MempoolMinFee),
+			MinRelayFeeRate: perVbyte(info.MinRelayTxFee),
+		},
+	}
+	for index, target := range TARGETS {
+		estimates.Targets = append(estimates.Targets, &Bucket{
+			Target:              target,
+			FeeRate:             bucketFeeRate(economical[index]),
+			ConservativeFeeRate: bucketFeeRate(conservative[index]),
+			Errors:              mergeErrors(economical[index].Errors, conservative[index].Errors),
+		})
+	}
+	if s.localBlocks > 0 {
+		if estimates.Local, blocks, err = s.localEstimate(ctx, height, blocks); err != nil {
+			return nil, err
+		}
+	}
+
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+	if s.cache.estimates == nil || height >= s.cache.height {
+		s.cache.height = height
+		s.cache.estimates = estimates
+		s.cache.blocks = blocks
+	}
+	return estimates, nil
+}
+
+// bucketFeeRate returns the fee rate of `estimate` in sat/vB, nil when the node has none
+func bucketFeeRate(estimate *fullnode.FeeEstimate) *float64 {
+	if estimate.FeeRate <= 0 {
+		return nil
+	}
+	feeRate := perVbyte(estimate.FeeRate)
+	return &feeRate
+}
+
+// mergeErrors returns the distinct errors of both modes, which are usually the same
+func mergeErrors(economical []string, conservative []string) []string {
+	var merged []string
+	seen := map[string]bool{}
+	for _, message := range append(append([]string{}, economical...), conservative...) {
+		if !seen[message] {
+			seen[message] = true
+			merged = append(merged, message)
+		}
+	}
+	return merged
+}
+
+// perVbyte converts a fee rate per kvB to sat/vB
+func perVbyte(feeRate fullnode.Amount) float64 {
+	return roundFeeRate(float64(feeRate) / 1000)
+}
+
+func roundFeeRate(feeRate float64) float64 {
+	return math.Round(feeRate*1000) / 1000
+}
@@ -0,0 +1,231 @@
+package fees
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	fmocks "github.com/ABMatrix/bitcoin-utxo-ms/fullnode/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+// forEveryTarget answers estimatesmartfee with `estimate` for each target
+func forEveryTarget(estimate *fullnode.FeeEstimate) []*fullnode.FeeEstimate {
+	var estimates []*fullnode.FeeEstimate
+	for range TARGETS {
+		estimates = append(estimates, estimate)
+	}
+	return estimates
+}
+
+func expectEstimates(f *fmocks.MockInterface, economical *fullnode.FeeEstimate, conservative *fullnode.FeeEstimate) {
+	f.EXPECT().EstimateSmartFees(gomock.Any(), TARGETS, fullnode.EstimateModeEconomical).Return(forEveryTarget(economical), nil)
+	f.EXPECT().EstimateSmartFees(gomock.Any(), TARGETS, fullnode.EstimateModeConservative).Return(forEveryTarget(conservative), nil)
+	f.EXPECT().GetMempoolInfo(gomock.Any()).Return(&fullnode.MempoolInfo{Size: 3, Bytes: 600, MempoolMinFee: 1000, MinRelayTxFee: 1234}, nil)
+}
+
+func describeFeeRate(feeRate *float64) string {
+	if feeRate == nil {
+		return "null"
+	}
+	return fmt.Sprint(*feeRate)
+}
+
+func TestEstimateBuckets(t *testing.T) {
+	tests := []struct {
+		name         string
+		economical   *fullnode.FeeEstimate
+		conservative *fullnode.FeeEstimate
+		feeRate      string
+		conservRate  string
+		errors       []string
+	}{
+		{
+			name:         "both modes",
+			economical:   &fullnode.FeeEstimate{FeeRate: 12345},
+			conservative: &fullnode.FeeEstimate{FeeRate: 20000},
+			feeRate:      "12.345",
+			conservRate:  "20",
+		},
+		{
+			name:         "no data yet",
+			economical:   &fullnode.FeeEstimate{Errors: []string{"Insufficient data or no feerate found"}},
+			conservative: &fullnode.FeeEstimate{Errors: []string{"Insufficient data or no feerate found"}},
+			feeRate:      "null",
+			conservRate:  "null",
+			errors:       []string{"Insufficient data or no feerate found"},
+		},
+		{
+			name:         "only economical",
+			economical:   &fullnode.FeeEstimate{FeeRate: 1000},
+			conservative: &fullnode.FeeEstimate{Errors: []string{"Insufficient data or no feerate found", "other"}},
+			feeRate:      "1",
+			conservRate:  "null",
+			errors:       []string{"Insufficient data or no feerate found", "other"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			f := fmocks.NewMockInterface(ctrl)
+			f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(100, nil)
+			expectEstimates(f, test.economical, test.conservative)
+
+			estimates, err := New(f).Estimate(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if estimates.Height != 100 || len(estimates.Targets) != len(TARGETS) {
+				t.Fatalf("expected %d targets at height 100, got %d at %d", len(TARGETS), len(estimates.Targets), estimates.Height)
+			}
+			for index, bucket := range estimates.Targets {
+				if bucket.Target != TARGETS[index] {
+					t.Fatalf("expected target %d, got %d", TARGETS[index], bucket.Target)
+				}
+				if describeFeeRate(bucket.FeeRate) != test.feeRate || describeFeeRate(bucket.ConservativeFeeRate) != test.conservRate {
+					t.Fatalf("expected fee rates %s and %s, got %s and %s", test.feeRate, test.conservRate,
+						describeFeeRate(bucket.FeeRate), describeFeeRate(bucket.ConservativeFeeRate))
+				}
+				if fmt.Sprint(bucket.Errors) != fmt.Sprint(test.errors) {
+					t.Fatalf("expected errors %v, got %v", test.errors, bucket.Errors)
+				}
+			}
+			mempool := estimates.Mempool
+			if mempool.TxCount != 3 || mempool.Vsize != 600 || mempool.MinFeeRate != 1 || mempool.MinRelayFeeRate != 1.234 {
+				t.Fatalf("unexpected mempool stats %+v", mempool)
+			}
+			if estimates.Local != nil {
+				t.Fatal("expected no local estimate without FEE_LOCAL_BLOCKS")
+			}
+		})
+	}
+}
+
+func TestEstimateIsCachedPerTip(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	f := fmocks.NewMockInterface(ctrl)
+	gomock.InOrder(
+		f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(100, nil).Times(2),
+		f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(101, nil),
+	)
+	expectEstimates(f, &fullnode.FeeEstimate{FeeRate: 1000}, &fullnode.FeeEstimate{FeeRate: 1000})
+	expectEstimates(f, &fullnode.FeeEstimate{FeeRate: 2000}, &fullnode.FeeEstimate{FeeRate: 2000})
+
+	s := New(f)
+	for _, expected := range []struct {
+		height  int
+		feeRate string
+	}{{100, "1"}, {100, "1"}, {101, "2"}} {
+		estimates, err := s.Estimate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if estimates.Height != expected.height || describeFeeRate(estimates.Targets[0].FeeRate) != expected.feeRate {
+			t.Fatalf("expected %s sat/vB at height %d, got %s at %d", expected.feeRate, expected.height,
+				describeFeeRate(estimates.Targets[0].FeeRate), estimates.Height)
+		}
+	}
+}
+
+func TestConcurrentEstimatesShareOneRefresh(t *testing.T) {
+	const callers = 20
+	ctrl := gomock.NewController(t)
+	f := fmocks.NewMockInterface(ctrl)
+	f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(100, nil).Times(callers)
+	// the refresh waits until every caller asked for the estimates
+	var asked sync.WaitGroup
+	asked.Add(callers)
+	f.EXPECT().EstimateSmartFees(gomock.Any(), TARGETS, fullnode.EstimateModeEconomical).DoAndReturn(
+		func(ctx context.Context, targets []int, mode fullnode.EstimateMode) ([]*fullnode.FeeEstimate, error) {
+			asked.Wait()
+			return forEveryTarget(&fullnode.FeeEstimate{FeeRate: 1000}), nil
+		})
+	f.EXPECT().EstimateSmartFees(gomock.Any(), TARGETS, fullnode.EstimateModeConservative).Return(forEveryTarget(&fullnode.FeeEstimate{FeeRate: 1000}), nil)
+	f.EXPECT().GetMempoolInfo(gomock.Any()).Return(&fullnode.MempoolInfo{}, nil)
+
+	s := New(f)
+	var done sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			ctx := &askedContext{Context: context.Background(), asked: &asked}
+			if _, err := s.Estimate(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	done.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// askedContext counts the caller as having asked once Estimate starts waiting on the context
+type askedContext struct {
+	context.Context
+	asked *sync.WaitGroup
+	once  sync.Once
+}
+
+func (c *askedContext) Done() <-chan struct{} {
+	c.once.Do(c.asked.Done)
+	return c.Context.Done()
+}
+
+func TestLocalEstimatePercentiles(t *testing.T) {
+	t.Setenv(ENV_FEE_LOCAL_BLOCKS, "2")
+	ctrl := gomock.NewController(t)
+	f := fmocks.NewMockInterface(ctrl)
+
+	coinbase := &fullnode.Transaction{Vsize: 150, TxIns: []*fullnode.TxIn{{Coinbase: "03"}}}
+	spend := func(fee fullnode.Amount, vsize int64) *fullnode.Transaction {
+		return &fullnode.Transaction{Fee: fee, Vsize: vsize, TxIns: []*fullnode.TxIn{{Txid: "a"}}}
+	}
+	blocks := map[int]*fullnode.Block{
+		9:  {Hash: "h9", Transactions: []*fullnode.Transaction{coinbase, spend(1000, 100), spend(200, 100)}},
+		10: {Hash: "h10", Transactions: []*fullnode.Transaction{coinbase, spend(5000, 200), spend(600, 100)}},
+		11: {Hash: "h11", Transactions: []*fullnode.Transaction{coinbase, spend(100, 100)}},
+	}
+	f.EXPECT().GetBlockHash(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int) (string, error) {
+		return blocks[height].Hash, nil
+	}).AnyTimes()
+	// each block is fetched once, the next tip only fetches the new block
+	for _, block := range blocks {
+		f.EXPECT().GetBlock(gomock.Any(), block.Hash).Return(block, nil)
+	}
+	gomock.InOrder(
+		f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(10, nil),
+		f.EXPECT().GetBestBlockHeight(gomock.Any()).Return(11, nil),
+	)
+	expectEstimates(f, &fullnode.FeeEstimate{FeeRate: 1000}, &fullnode.FeeEstimate{FeeRate: 1000})
+	expectEstimates(f, &fullnode.FeeEstimate{FeeRate: 1000}, &fullnode.FeeEstimate{FeeRate: 1000})
+
+	s := New(f)
+	for _, expected := range []struct {
+		height      int
+		percentiles string
+	}{
+		// 2 (100 vB), 6 (100 vB), 10 (100 vB) and 25 sat/vB (200 vB) in blocks 9 and 10
+		{10, "[10:2 25:6 50:10 75:25 90:25]"},
+		// 1 (100 vB), 6 (100 vB) and 25 sat/vB (200 vB) in blocks 10 and 11
+		{11, "[10:1 25:1 50:6 75:25 90:25]"},
+	} {
+		estimates, err := s.Estimate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var percentiles []string
+		for _, percentile := range estimates.Local.Percentiles {
+			percentiles = append(percentiles, fmt.Sprintf("%d:%v", percentile.Percentile, percentile.FeeRate))
+		}
+		if estimates.Local.Blocks != 2 || fmt.Sprint(percentiles) != expected.percentiles {
+			t.Fatalf("expected %s over 2 blocks at height %d, got %v over %d", expected.percentiles, expected.height,
+				percentiles, estimates.Local.Blocks)
+		}
+	}
+}
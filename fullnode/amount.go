@@ -0,0 +1,83 @@
+package fullnode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	SATOSHI_PER_BITCOIN = 100000000
+	AMOUNT_DECIMALS     = 8
+)
+
+// Amount is a value in satoshis. bitcoind writes values as BTC with up to 8 decimals,
+// they are parsed digit by digit since float64 can't represent most of them exactly
+type Amount int64
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value := strings.TrimSpace(string(data))
+	if value == "null" {
+		return nil
+	}
+	amount, err := ParseAmount(value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// String formats the amount in BTC with 8 decimals, the way bitcoind does
+func (a Amount) String() string {
+	sign := ""
+	value := int64(a)
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, value/SATOSHI_PER_BITCOIN, value%SATOSHI_PER_BITCOIN)
+}
+
+// ParseAmount parses a decimal BTC value such as "0.29" into satoshis
+func ParseAmount(value string) (Amount, error) {
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(value, "-")
+
+	whole, fraction := digits, ""
+	if point := strings.IndexByte(digits, '.'); point >= 0 {
+		whole, fraction = digits[:point], digits[point+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > AMOUNT_DECIMALS {
+		return 0, fmt.Errorf("invalid amount %q: more than %d decimals", value, AMOUNT_DECIMALS)
+	}
+	fraction += strings.Repeat("0", AMOUNT_DECIMALS-len(fraction))
+	if whole == "" {
+		whole = "0"
+	}
+
+	coins, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", value, err)
+	}
+	satoshis, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", value, err)
+	}
+	if coins > (1<<63-1-satoshis)/SATOSHI_PER_BITCOIN {
+		return 0, fmt.Errorf("invalid amount %q: out of range", value)
+	}
+
+	amount := Amount(coins*SATOSHI_PER_BITCOIN + satoshis)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
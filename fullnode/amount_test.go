@@ -0,0 +1,97 @@
+package fullnode
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value  string
+		amount Amount
+		valid  bool
+	}{
+		{"0.29", 29000000, true},
+		{"21000000.00000000", 21000000 * SATOSHI_PER_BITCOIN, true},
+		{"-0.00000001", -1, true},
+		{"0.00000001", 1, true},
+		{"1.10000000", 110000000, true},
+		{"0.000000010", 1, true}, // trailing zeros don't count as decimals
+		{".5", 50000000, true},
+		{"1.", 100000000, true},
+		{"0", 0, true},
+		{"92233720368.54775807", 1<<63 - 1, true},
+		{"92233720368.54775808", 0, false},
+		{"100000000000", 0, false},
+		{"0.123456789", 0, false},
+		{"", 0, false},
+		{".", 0, false},
+		{"-", 0, false},
+		{"1e-8", 0, false},
+		{"+1", 0, false},
+		{"--1", 0, false},
+		{"1.2.3", 0, false},
+		{"null", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			amount, err := ParseAmount(test.value)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid to be %t, got error %v", test.valid, err)
+			}
+			if amount != test.amount {
+				t.Errorf("expected %d satoshis, got %d", test.amount, amount)
+			}
+		})
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		json   string
+		amount Amount
+		valid  bool
+	}{
+		{`{"value": 0.29}`, 29000000, true},
+		{`{"value": 21000000.00000000}`, 21000000 * SATOSHI_PER_BITCOIN, true},
+		{`{"value": -0.00000001}`, -1, true},
+		{`{"value": null}`, 0, true},
+		{`{}`, 0, true},
+		{`{"value": 0.123456789}`, 0, false},
+		{`{"value": 1e400}`, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			var decoded struct {
+				Value Amount `json:"value"`
+			}
+			err := json.Unmarshal([]byte(test.json), &decoded)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid to be %t, got error %v", test.valid, err)
+			}
+			if decoded.Value != test.amount {
+				t.Errorf("expected %d satoshis, got %d", test.amount, decoded.Value)
+			}
+		})
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		value  string
+	}{
+		{29000000, "0.29000000"},
+		{-1, "-0.00000001"},
+		{21000000 * SATOSHI_PER_BITCOIN, "21000000.00000000"},
+		{0, "0.00000000"},
+	}
+	for _, test := range tests {
+		if value := test.amount.String(); value != test.value {
+			t.Errorf("expected %s, got %s", test.value, value)
+		}
+		if parsed, err := ParseAmount(test.value); err != nil || parsed != test.amount {
+			t.Errorf("expected %s to parse back to %d, got %d with error %v", test.value, test.amount, parsed, err)
+		}
+	}
+}
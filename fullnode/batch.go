@@ -0,0 +1,182 @@
+package fullnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	ENV_RPC_BATCH_SIZE = "RPC_BATCH_SIZE"
+	// DEFAULT_BATCH_SIZE is kept small as a fully decoded block can weigh tens of megabytes of JSON
+	DEFAULT_BATCH_SIZE = 5
+)
+
+// rpcBatchCall sends `payloads` in one JSON-RPC batch and returns the responses in the same order.
+// Errors of single requests are left in their response, except for transient ones which retry the batch
+func (s server) rpcBatchCall(ctx context.Context, payloads []*Payload) ([]*Response, error) {
+	var responses []*Response
+	err := s.withRetry(ctx, fmt.Sprintf("batch of %d", len(payloads)), func(ctx context.Context) error {
+		var err error
+		responses, err = s.batchCall(ctx, payloads)
+		return err
+	})
+	return responses, err
+}
+
+func (s server) batchCall(ctx context.Context, payloads []*Payload) ([]*Response, error) {
+	for index, payload := range payloads {
+		if payload.JsonRpc == "" {
+			payload.JsonRpc = "1.0"
+		}
+		payload.ID = strconv.Itoa(index)
+	}
+
+	body, err := s.post(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	var unordered []*Response
+	if err := json.Unmarshal(body, &unordered); err != nil {
+		// a failure of the whole batch comes back as a single response
+		response := &Response{}
+		if json.Unmarshal(body, response) == nil && response.Error != nil {
+			return nil, response.Error
+		}
+		return nil, &DecodeError{Err: err}
+	}
+
+	responses := make([]*Response, len(payloads))
+	for _, response := range unordered {
+		index, err := strconv.Atoi(response.ID)
+		if err != nil || index < 0 || index >= len(responses) {
+			return nil, &DecodeError{Err: fmt.Errorf("unexpected response id %q", response.ID)}
+		}
+		if response.Error != nil && IsTransient(response.Error) {
+			return nil, response.Error
+		}
+		responses[index] = response
+	}
+	for index, response := range responses {
+		if response == nil {
+			return nil, &DecodeError{Err: fmt.Errorf("missing response for request %d", index)}
+		}
+	}
+	return responses, nil
+}
+
+// GetBlocksInRange returns the blocks from height `from` to `to` (inclusive) in order, stopping early
+// at the tip. The hashes of the next batch are looked up while the blocks of the current one are fetched
+func (s server) GetBlocksInRange(ctx context.Context, from int, to int) ([]*Block, error) {
+	if to < from {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+
+	hashes, err := s.getBlockHashes(ctx, from, s.batchEnd(from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*Block
+	for start := from; len(hashes) > 0; {
+		next := start + len(hashes)
+		// a short batch means the tip has been reached
+		lookupNext := next <= to && len(hashes) == s.batchEnd(start, to)-start+1
+
+		var nextHashes []string
+		var nextErr error
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if lookupNext {
+				nextHashes, nextErr = s.getBlockHashes(ctx, next, s.batchEnd(next, to))
+			}
+		}()
+
+		batchBlocks, err := s.getBlocks(ctx, hashes)
+		<-done
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, batchBlocks...)
+
+		if nextErr != nil && !errors.Is(nextErr, ErrNotFound) {
+			return nil, nextErr
+		}
+		start, hashes = next, nextHashes
+	}
+	return blocks, nil
+}
+
+func (s server) batchEnd(from int, to int) int {
+	if end := from + s.batchSize - 1; end < to {
+		return end
+	}
+	return to
+}
+
+// getBlockHashes returns the hashes from height `from` to `to`, cut short at the tip
+func (s server) getBlockHashes(ctx context.Context, from int, to int) ([]string, error) {
+	var payloads []*Payload
+	for height := from; height <= to; height++ {
+		payloads = append(payloads, &Payload{
+			Method: RpcMethodsGetBlockHash,
+			Params: []interface{}{height},
+		})
+	}
+
+	responses, err := s.rpcBatchCall(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for index, response := range responses {
+		if response.Error != nil {
+			err := notFoundOnCode(response.Error, RPC_INVALID_PARAMETER)
+			if errors.Is(err, ErrNotFound) && index > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		var hash string
+		if err := json.Unmarshal(response.Result, &hash); err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+func (s server) getBlocks(ctx context.Context, hashes []string) ([]*Block, error) {
+	var payloads []*Payload
+	for _, hash := range hashes {
+		payloads = append(payloads, &Payload{
+			Method: RpcMethodsGetBlock,
+			Params: []interface{}{hash, BlockVerbosityTransactions},
+		})
+	}
+
+	responses, err := s.rpcBatchCall(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*Block, 0, len(responses))
+	for _, response := range responses {
+		if response.Error != nil {
+			return nil, notFoundOnCode(response.Error, RPC_INVALID_ADDRESS_OR_KEY)
+		}
+
+		block := &Block{}
+		if err := json.Unmarshal(response.Result, block); err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
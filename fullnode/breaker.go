@@ -0,0 +1,116 @@
+package fullnode
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// ErrCircuitOpen is returned without calling bitcoind while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open, bitcoind is unavailable")
+
+// Health describes the availability of bitcoind as seen by the circuit breaker
+type Health struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenUntil           *time.Time   `json:"open_until,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// circuitBreaker opens after `threshold` consecutive failed calls and lets a single call through
+// once `cooldown` has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool // a call is being let through while half-open
+	lastError string
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a call may go through
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state() {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.openUntil = time.Time{}
+	b.lastError = ""
+}
+
+func (b *circuitBreaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.trial || b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+	b.trial = false
+}
+
+// abandon gives up a call without an outcome, a half-open circuit lets another call through
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// state must be called with the lock held
+func (b *circuitBreaker) state() CircuitState {
+	if b.failures < b.threshold && !b.trial {
+		return CircuitClosed
+	}
+	if time.Now().Before(b.openUntil) {
+		return CircuitOpen
+	}
+	return CircuitHalfOpen
+}
+
+func (b *circuitBreaker) health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := Health{
+		State:               b.state(),
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if health.State == CircuitOpen {
+		openUntil := b.openUntil
+		health.OpenUntil = &openUntil
+	}
+	return health
+}
@@ -0,0 +1,91 @@
+package fullnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const COOLDOWN = 50 * time.Millisecond
+
+var errNodeDown = errors.New("connection refused")
+
+func expectState(t *testing.T, b *circuitBreaker, state CircuitState) {
+	t.Helper()
+	if health := b.health(); health.State != state {
+		t.Fatalf("expected the circuit to be %s, got %s", state, health.State)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(3, COOLDOWN)
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatal("a closed circuit refused a call")
+		}
+		b.failure(errNodeDown)
+	}
+	expectState(t, b, CircuitClosed)
+
+	b.allow()
+	b.failure(errNodeDown)
+	expectState(t, b, CircuitOpen)
+	if b.allow() {
+		t.Fatal("an open circuit let a call through")
+	}
+	health := b.health()
+	if health.ConsecutiveFailures != 3 || health.LastError != errNodeDown.Error() || health.OpenUntil == nil {
+		t.Fatalf("unexpected health %+v", health)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newCircuitBreaker(3, COOLDOWN)
+	for i := 0; i < 2; i++ {
+		b.allow()
+		b.failure(errNodeDown)
+	}
+	b.allow()
+	b.success()
+	b.allow()
+	b.failure(errNodeDown)
+	expectState(t, b, CircuitClosed)
+	if health := b.health(); health.ConsecutiveFailures != 1 {
+		t.Fatalf("expected 1 failure after a success, got %d", health.ConsecutiveFailures)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome func(b *circuitBreaker)
+		state   CircuitState
+	}{
+		{"trial succeeds", func(b *circuitBreaker) { b.success() }, CircuitClosed},
+		{"trial fails", func(b *circuitBreaker) { b.failure(errNodeDown) }, CircuitOpen},
+		{"trial is abandoned", func(b *circuitBreaker) { b.abandon() }, CircuitHalfOpen},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newCircuitBreaker(1, COOLDOWN)
+			b.allow()
+			b.failure(errNodeDown)
+			expectState(t, b, CircuitOpen)
+
+			time.Sleep(COOLDOWN)
+			expectState(t, b, CircuitHalfOpen)
+			if !b.allow() {
+				t.Fatal("a half-open circuit refused the trial call")
+			}
+			if b.allow() {
+				t.Fatal("a half-open circuit let a second call through during the trial")
+			}
+
+			test.outcome(b)
+			expectState(t, b, test.state)
+			if allowed := b.allow(); allowed != (test.state != CircuitOpen) {
+				t.Fatalf("expected a call to be allowed: %t, got %t", test.state != CircuitOpen, allowed)
+			}
+		})
+	}
+}
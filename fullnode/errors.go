@@ -0,0 +1,68 @@
+package fullnode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// bitcoind error codes, see src/rpc/protocol.h
+const (
+	RPC_INVALID_ADDRESS_OR_KEY  = -5
+	RPC_INVALID_PARAMETER       = -8
+	RPC_DESERIALIZATION_ERROR   = -22
+	RPC_VERIFY_ERROR            = -25
+	RPC_VERIFY_REJECTED         = -26
+	RPC_VERIFY_ALREADY_IN_CHAIN = -27
+)
+
+// ErrNotFound is returned when the requested block doesn't exist, or doesn't exist yet
+var ErrNotFound = errors.New("not found")
+
+// TransportError is returned when bitcoind couldn't be reached or answered with something other than JSON-RPC
+type TransportError struct {
+	StatusCode int // 0 when no response was received
+	Err        error
+}
+
+func (e *TransportError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("transport error (http status %d): %s", e.StatusCode, e.Err.Error())
+	}
+	return "transport error: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// RPCError is the error reported by bitcoind in a JSON-RPC response
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// DecodeError is returned when a response couldn't be decoded into the expected result
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "decode error: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// notFoundOnCode translates the rpc error `code` into ErrNotFound and leaves any other error as it is
+func notFoundOnCode(err error, code int) error {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == code {
+		return fmt.Errorf("%w: %s", ErrNotFound, rpcErr.Message)
+	}
+	return err
+}
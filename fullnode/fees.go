@@ -0,0 +1,53 @@
+package fullnode
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// EstimateSmartFees asks the node for the fee rate needed to confirm within each of `targets` blocks, in one batch.
+// The estimates are in the same order, with no fee rate and an explanation in Errors when the node has too little data
+func (s server) EstimateSmartFees(ctx context.Context, targets []int, mode EstimateMode) ([]*FeeEstimate, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	var payloads []*Payload
+	for _, target := range targets {
+		payloads = append(payloads, &Payload{
+			Method: RpcMethodsEstimateSmartFee,
+			Params: []interface{}{target, mode},
+		})
+	}
+
+	responses, err := s.rpcBatchCall(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	estimates := make([]*FeeEstimate, len(responses))
+	for index, response := range responses {
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		estimates[index] = &FeeEstimate{}
+		if err := json.Unmarshal(response.Result, estimates[index]); err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+	}
+	return estimates, nil
+}
+
+// GetMempoolInfo returns the size of the node's mempool and the fee rates it currently accepts
+func (s server) GetMempoolInfo(ctx context.Context) (*MempoolInfo, error) {
+	payload := &Payload{
+		Method: RpcMethodsGetMempoolInfo,
+		Params: []interface{}{},
+	}
+
+	info := &MempoolInfo{}
+	if err := s.rpcCall(ctx, payload, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -1,9 +1,24 @@
 package fullnode
 
+import "context"
+
 //go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=fullnode
 type Interface interface {
-	GetBestBlockHeight() int
-	GetBlockAtHeight(height int) *Block
-	GetBestBlock() *Block
-	GetBlock(hash string) *Block
+	GetBestBlockHeight(ctx context.Context) (int, error)
+	GetBlockAtHeight(ctx context.Context, height int) (*Block, error)
+	GetBestBlock(ctx context.Context) (*Block, error)
+	GetBlock(ctx context.Context, hash string) (*Block, error)
+	GetBlockHash(ctx context.Context, height int) (string, error)
+	GetBlockWithPrevouts(ctx context.Context, hash string) (*Block, error)
+	GetBlocksInRange(ctx context.Context, from int, to int) ([]*Block, error)
+	GetBlockHeaderHex(ctx context.Context, hash string) (string, error)
+	GetRawMempool(ctx context.Context) ([]string, error)
+	GetRawTransactions(ctx context.Context, txids []string) ([]*Transaction, error)
+	GetRawTransactionHex(ctx context.Context, txid string, blockHash string) (string, error)
+	GetTxOuts(ctx context.Context, outpoints []*Outpoint) ([]*UnspentTxOut, error)
+	TestMempoolAccept(ctx context.Context, rawTx string) (*MempoolAcceptResult, error)
+	SendRawTransaction(ctx context.Context, rawTx string) (string, error)
+	EstimateSmartFees(ctx context.Context, targets []int, mode EstimateMode) ([]*FeeEstimate, error)
+	GetMempoolInfo(ctx context.Context) (*MempoolInfo, error)
+	Health() Health
 }
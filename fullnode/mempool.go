@@ -0,0 +1,116 @@
+package fullnode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// GetRawMempool returns the ids of the transactions currently in the mempool
+func (s server) GetRawMempool(ctx context.Context) ([]string, error) {
+	payload := &Payload{
+		Method: RpcMethodsGetRawMempool,
+		Params: []interface{}{false},
+	}
+
+	var txids []string
+	if err := s.rpcCall(ctx, payload, &txids); err != nil {
+		return nil, err
+	}
+	return txids, nil
+}
+
+// GetRawTransactions returns the decoded transactions for `txids`, fetched in batches.
+// Transactions which the node no longer knows about, e.g. evicted from the mempool in the meantime, are left out
+func (s server) GetRawTransactions(ctx context.Context, txids []string) ([]*Transaction, error) {
+	var transactions []*Transaction
+	for start := 0; start < len(txids); start += s.batchSize {
+		end := start + s.batchSize
+		if end > len(txids) {
+			end = len(txids)
+		}
+
+		var payloads []*Payload
+		for _, txid := range txids[start:end] {
+			payloads = append(payloads, &Payload{
+				Method: RpcMethodsGetRawTx,
+				Params: []interface{}{txid, true},
+			})
+		}
+
+		responses, err := s.rpcBatchCall(ctx, payloads)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, response := range responses {
+			if response.Error != nil {
+				// bitcoind answers "No such mempool or blockchain transaction" for unknown txids
+				if err := notFoundOnCode(response.Error, RPC_INVALID_ADDRESS_OR_KEY); errors.Is(err, ErrNotFound) {
+					continue
+				}
+				return nil, response.Error
+			}
+
+			transaction := &Transaction{}
+			if err := json.Unmarshal(response.Result, transaction); err != nil {
+				return nil, &DecodeError{Err: err}
+			}
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, nil
+}
+
+// GetRawTransactionHex returns the serialized transaction in hex. Without -txindex bitcoind only finds confirmed
+// transactions when given the hash of their block, `blockHash` may be empty for mempool transactions
+func (s server) GetRawTransactionHex(ctx context.Context, txid string, blockHash string) (string, error) {
+	params := []interface{}{txid, false}
+	if blockHash != "" {
+		params = append(params, blockHash)
+	}
+	payload := &Payload{
+		Method: RpcMethodsGetRawTx,
+		Params: params,
+	}
+
+	var rawTx string
+	if err := s.rpcCall(ctx, payload, &rawTx); err != nil {
+		return "", notFoundOnCode(err, RPC_INVALID_ADDRESS_OR_KEY)
+	}
+	return rawTx, nil
+}
+
+// TestMempoolAccept checks whether the node would accept a serialized transaction into its mempool, without
+// submitting it. Policy and consensus failures are reported in the result, undecodable transactions as *RPCError
+func (s server) TestMempoolAccept(ctx context.Context, rawTx string) (*MempoolAcceptResult, error) {
+	payload := &Payload{
+		Method: RpcMethodsTestMempool,
+		Params: []interface{}{[]string{rawTx}},
+	}
+
+	var results []*MempoolAcceptResult
+	if err := s.rpcCall(ctx, payload, &results); err != nil {
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, &DecodeError{Err: fmt.Errorf("expected 1 result, got %d", len(results))}
+	}
+	return results[0], nil
+}
+
+// SendRawTransaction submits a serialized transaction to the node's mempool and relays it, returning its txid.
+// Rejections come back as *RPCError with bitcoind's reason as the message
+func (s server) SendRawTransaction(ctx context.Context, rawTx string) (string, error) {
+	payload := &Payload{
+		Method: RpcMethodsSendRawTx,
+		Params: []interface{}{rawTx},
+	}
+
+	var txid string
+	if err := s.rpcCall(ctx, payload, &txid); err != nil {
+		return "", err
+	}
+	return txid, nil
+}
@@ -5,6 +5,7 @@
 package fullnode
 
 import (
+	context "context"
 	reflect "reflect"
 
 	fullnode "github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
@@ -34,58 +35,256 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// EstimateSmartFees mocks base method.
+func (m *MockInterface) EstimateSmartFees(ctx context.Context, targets []int, mode fullnode.EstimateMode) ([]*fullnode.FeeEstimate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateSmartFees", ctx, targets, mode)
+	ret0, _ := ret[0].([]*fullnode.FeeEstimate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateSmartFees indicates an expected call of EstimateSmartFees.
+func (mr *MockInterfaceMockRecorder) EstimateSmartFees(ctx, targets, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateSmartFees", reflect.TypeOf((*MockInterface)(nil).EstimateSmartFees), ctx, targets, mode)
+}
+
 // GetBestBlock mocks base method.
-func (m *MockInterface) GetBestBlock() *fullnode.Block {
+func (m *MockInterface) GetBestBlock(ctx context.Context) (*fullnode.Block, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBestBlock")
+	ret := m.ctrl.Call(m, "GetBestBlock", ctx)
 	ret0, _ := ret[0].(*fullnode.Block)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetBestBlock indicates an expected call of GetBestBlock.
-func (mr *MockInterfaceMockRecorder) GetBestBlock() *gomock.Call {
+func (mr *MockInterfaceMockRecorder) GetBestBlock(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBestBlock", reflect.TypeOf((*MockInterface)(nil).GetBestBlock))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBestBlock", reflect.TypeOf((*MockInterface)(nil).GetBestBlock), ctx)
 }
 
 // GetBestBlockHeight mocks base method.
-func (m *MockInterface) GetBestBlockHeight() int {
+func (m *MockInterface) GetBestBlockHeight(ctx context.Context) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBestBlockHeight")
+	ret := m.ctrl.Call(m, "GetBestBlockHeight", ctx)
 	ret0, _ := ret[0].(int)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetBestBlockHeight indicates an expected call of GetBestBlockHeight.
-func (mr *MockInterfaceMockRecorder) GetBestBlockHeight() *gomock.Call {
+func (mr *MockInterfaceMockRecorder) GetBestBlockHeight(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBestBlockHeight", reflect.TypeOf((*MockInterface)(nil).GetBestBlockHeight))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBestBlockHeight", reflect.TypeOf((*MockInterface)(nil).GetBestBlockHeight), ctx)
 }
 
 // GetBlock mocks base method.
-func (m *MockInterface) GetBlock(hash string) *fullnode.Block {
+func (m *MockInterface) GetBlock(ctx context.Context, hash string) (*fullnode.Block, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBlock", hash)
+	ret := m.ctrl.Call(m, "GetBlock", ctx, hash)
 	ret0, _ := ret[0].(*fullnode.Block)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetBlock indicates an expected call of GetBlock.
-func (mr *MockInterfaceMockRecorder) GetBlock(hash interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) GetBlock(ctx, hash interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlock", reflect.TypeOf((*MockInterface)(nil).GetBlock), hash)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlock", reflect.TypeOf((*MockInterface)(nil).GetBlock), ctx, hash)
 }
 
 // GetBlockAtHeight mocks base method.
-func (m *MockInterface) GetBlockAtHeight(height int) *fullnode.Block {
+func (m *MockInterface) GetBlockAtHeight(ctx context.Context, height int) (*fullnode.Block, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBlockAtHeight", height)
+	ret := m.ctrl.Call(m, "GetBlockAtHeight", ctx, height)
 	ret0, _ := ret[0].(*fullnode.Block)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetBlockAtHeight indicates an expected call of GetBlockAtHeight.
-func (mr *MockInterfaceMockRecorder) GetBlockAtHeight(height interface{}) *gomock.Call {
+func (mr *MockInterfaceMockRecorder) GetBlockAtHeight(ctx, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockAtHeight", reflect.TypeOf((*MockInterface)(nil).GetBlockAtHeight), ctx, height)
+}
+
+// GetBlockHash mocks base method.
+func (m *MockInterface) GetBlockHash(ctx context.Context, height int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockHash", ctx, height)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockHash indicates an expected call of GetBlockHash.
+func (mr *MockInterfaceMockRecorder) GetBlockHash(ctx, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockHash", reflect.TypeOf((*MockInterface)(nil).GetBlockHash), ctx, height)
+}
+
+// GetBlockHeaderHex mocks base method.
+func (m *MockInterface) GetBlockHeaderHex(ctx context.Context, hash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockHeaderHex", ctx, hash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockHeaderHex indicates an expected call of GetBlockHeaderHex.
+func (mr *MockInterfaceMockRecorder) GetBlockHeaderHex(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockHeaderHex", reflect.TypeOf((*MockInterface)(nil).GetBlockHeaderHex), ctx, hash)
+}
+
+// GetBlockWithPrevouts mocks base method.
+func (m *MockInterface) GetBlockWithPrevouts(ctx context.Context, hash string) (*fullnode.Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockWithPrevouts", ctx, hash)
+	ret0, _ := ret[0].(*fullnode.Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockWithPrevouts indicates an expected call of GetBlockWithPrevouts.
+func (mr *MockInterfaceMockRecorder) GetBlockWithPrevouts(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockWithPrevouts", reflect.TypeOf((*MockInterface)(nil).GetBlockWithPrevouts), ctx, hash)
+}
+
+// GetBlocksInRange mocks base method.
+func (m *MockInterface) GetBlocksInRange(ctx context.Context, from, to int) ([]*fullnode.Block, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlocksInRange", ctx, from, to)
+	ret0, _ := ret[0].([]*fullnode.Block)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlocksInRange indicates an expected call of GetBlocksInRange.
+func (mr *MockInterfaceMockRecorder) GetBlocksInRange(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlocksInRange", reflect.TypeOf((*MockInterface)(nil).GetBlocksInRange), ctx, from, to)
+}
+
+// GetMempoolInfo mocks base method.
+func (m *MockInterface) GetMempoolInfo(ctx context.Context) (*fullnode.MempoolInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMempoolInfo", ctx)
+	ret0, _ := ret[0].(*fullnode.MempoolInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMempoolInfo indicates an expected call of GetMempoolInfo.
+func (mr *MockInterfaceMockRecorder) GetMempoolInfo(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMempoolInfo", reflect.TypeOf((*MockInterface)(nil).GetMempoolInfo), ctx)
+}
+
+// GetRawMempool mocks base method.
+func (m *MockInterface) GetRawMempool(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRawMempool", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRawMempool indicates an expected call of GetRawMempool.
+func (mr *MockInterfaceMockRecorder) GetRawMempool(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRawMempool", reflect.TypeOf((*MockInterface)(nil).GetRawMempool), ctx)
+}
+
+// GetRawTransactionHex mocks base method.
+func (m *MockInterface) GetRawTransactionHex(ctx context.Context, txid, blockHash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRawTransactionHex", ctx, txid, blockHash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRawTransactionHex indicates an expected call of GetRawTransactionHex.
+func (mr *MockInterfaceMockRecorder) GetRawTransactionHex(ctx, txid, blockHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRawTransactionHex", reflect.TypeOf((*MockInterface)(nil).GetRawTransactionHex), ctx, txid, blockHash)
+}
+
+// GetRawTransactions mocks base method.
+func (m *MockInterface) GetRawTransactions(ctx context.Context, txids []string) ([]*fullnode.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRawTransactions", ctx, txids)
+	ret0, _ := ret[0].([]*fullnode.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRawTransactions indicates an expected call of GetRawTransactions.
+func (mr *MockInterfaceMockRecorder) GetRawTransactions(ctx, txids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRawTransactions", reflect.TypeOf((*MockInterface)(nil).GetRawTransactions), ctx, txids)
+}
+
+// GetTxOuts mocks base method.
+func (m *MockInterface) GetTxOuts(ctx context.Context, outpoints []*fullnode.Outpoint) ([]*fullnode.UnspentTxOut, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTxOuts", ctx, outpoints)
+	ret0, _ := ret[0].([]*fullnode.UnspentTxOut)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTxOuts indicates an expected call of GetTxOuts.
+func (mr *MockInterfaceMockRecorder) GetTxOuts(ctx, outpoints interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTxOuts", reflect.TypeOf((*MockInterface)(nil).GetTxOuts), ctx, outpoints)
+}
+
+// Health mocks base method.
+func (m *MockInterface) Health() fullnode.Health {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health")
+	ret0, _ := ret[0].(fullnode.Health)
+	return ret0
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockInterfaceMockRecorder) Health() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockInterface)(nil).Health))
+}
+
+// SendRawTransaction mocks base method.
+func (m *MockInterface) SendRawTransaction(ctx context.Context, rawTx string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendRawTransaction", ctx, rawTx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendRawTransaction indicates an expected call of SendRawTransaction.
+func (mr *MockInterfaceMockRecorder) SendRawTransaction(ctx, rawTx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendRawTransaction", reflect.TypeOf((*MockInterface)(nil).SendRawTransaction), ctx, rawTx)
+}
+
+// TestMempoolAccept mocks base method.
+func (m *MockInterface) TestMempoolAccept(ctx context.Context, rawTx string) (*fullnode.MempoolAcceptResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TestMempoolAccept", ctx, rawTx)
+	ret0, _ := ret[0].(*fullnode.MempoolAcceptResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TestMempoolAccept indicates an expected call of TestMempoolAccept.
+func (mr *MockInterfaceMockRecorder) TestMempoolAccept(ctx, rawTx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockAtHeight", reflect.TypeOf((*MockInterface)(nil).GetBlockAtHeight), height)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TestMempoolAccept", reflect.TypeOf((*MockInterface)(nil).TestMempoolAccept), ctx, rawTx)
 }
@@ -1,14 +1,25 @@
 package fullnode
 
+import "encoding/json"
+
 type ScriptType string
 
+// the script types reported by bitcoind in scriptPubKey.type
 const (
-	ScriptType_P2PK        ScriptType = "pubkey"
-	ScriptType_P2PKH       ScriptType = "pubkeyhash"
-	ScriptType_P2SH        ScriptType = "scripthash"
-	ScriptType_P2WKH       ScriptType = "witness_v0_keyhash"
-	ScriptType_P2WSH       ScriptType = "witness_v0_scripthash"
-	ScriptType_NonStandard ScriptType = "nulldata"
+	ScriptType_P2PK           ScriptType = "pubkey"
+	ScriptType_P2PKH          ScriptType = "pubkeyhash"
+	ScriptType_P2SH           ScriptType = "scripthash"
+	ScriptType_P2WKH          ScriptType = "witness_v0_keyhash"
+	ScriptType_P2WSH          ScriptType = "witness_v0_scripthash"
+	ScriptType_P2TR           ScriptType = "witness_v1_taproot"
+	ScriptType_WitnessUnknown ScriptType = "witness_unknown" // witness versions without a defined meaning yet
+	ScriptType_Anchor         ScriptType = "anchor"          // pay-to-anchor, bitcoind >= 28.0
+	ScriptType_Multisig       ScriptType = "multisig"
+	ScriptType_NullData       ScriptType = "nulldata"
+	ScriptType_NonStandard    ScriptType = "nonstandard"
+
+	// SCRIPT_TYPE_WITNESS_PREFIX starts the type of every witness program, including versions named in the future
+	SCRIPT_TYPE_WITNESS_PREFIX = "witness_"
 )
 
 type TxIn struct {
@@ -18,6 +29,15 @@ type TxIn struct {
 	Coinbase    string   `json:"coinbase"`
 	TxinWitness []string `json:"txinwitness"`
 	Sequence    int64    `json:"sequence"`
+	Prevout     *Prevout `json:"prevout,omitempty"` // only present with getblock verbosity 3
+}
+
+// Prevout is the output spent by a TxIn, as reported by getblock with verbosity 3
+type Prevout struct {
+	Generated bool    `json:"generated"`
+	Height    int     `json:"height"`
+	Value     Amount  `json:"value"`
+	Script    *Script `json:"scriptPubKey,omitempty"`
 }
 
 type Script struct {
@@ -28,7 +48,7 @@ type Script struct {
 }
 
 type TxOut struct {
-	Value  float64 `json:"value"`
+	Value  Amount  `json:"value"`
 	Index  int     `json:"n"`
 	Script *Script `json:"scriptPubKey,omitempty"`
 }
@@ -44,7 +64,62 @@ type Transaction struct {
 	TxIns    []*TxIn  `json:"vin"`
 	TxOuts   []*TxOut `json:"vout"`
 	Hex      string   `json:"hex"`
-	Fee      float64  `json:"fee,omitempty"`
+	Fee      Amount   `json:"fee,omitempty"`
+}
+
+// MempoolAcceptResult is bitcoind's verdict on a transaction, as reported by testmempoolaccept
+type MempoolAcceptResult struct {
+	Txid          string            `json:"txid"`
+	Wtxid         string            `json:"wtxid"`
+	Allowed       bool              `json:"allowed"`
+	Vsize         int64             `json:"vsize"`          // only when allowed
+	Fees          *MempoolAcceptFee `json:"fees,omitempty"` // only when allowed
+	RejectReason  string            `json:"reject-reason"`  // only when not allowed
+	RejectDetails string            `json:"reject-details"` // bitcoind >= 29.0
+}
+
+type MempoolAcceptFee struct {
+	Base Amount `json:"base"`
+}
+
+type EstimateMode string
+
+const (
+	EstimateModeEconomical   EstimateMode = "economical"
+	EstimateModeConservative EstimateMode = "conservative"
+)
+
+// FeeEstimate is the answer of estimatesmartfee, FeeRate is per kvB and 0 when the node has no estimate
+type FeeEstimate struct {
+	FeeRate Amount   `json:"feerate"`
+	Errors  []string `json:"errors"`
+	Blocks  int      `json:"blocks"` // the target the estimate was actually found for
+}
+
+// MempoolInfo is the answer of getmempoolinfo, fee rates are per kvB
+type MempoolInfo struct {
+	Loaded        bool   `json:"loaded"`
+	Size          int    `json:"size"`  // number of transactions
+	Bytes         int64  `json:"bytes"` // sum of their vsizes
+	Usage         int64  `json:"usage"`
+	TotalFee      Amount `json:"total_fee"`
+	MaxMempool    int64  `json:"maxmempool"`
+	MempoolMinFee Amount `json:"mempoolminfee"`
+	MinRelayTxFee Amount `json:"minrelaytxfee"`
+}
+
+type Outpoint struct {
+	Txid string
+	Vout int
+}
+
+// UnspentTxOut is an output of the node's UTXO set, as reported by gettxout
+type UnspentTxOut struct {
+	BestBlock     string  `json:"bestblock"`
+	Confirmations int     `json:"confirmations"`
+	Value         Amount  `json:"value"`
+	Script        *Script `json:"scriptPubKey,omitempty"`
+	Coinbase      bool    `json:"coinbase"`
 }
 
 type Block struct {
@@ -69,13 +144,8 @@ type Block struct {
 	Transactions      []*Transaction `json:"tx"`
 }
 
-type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
 type Response struct {
-	Result interface{} `json:"result"`
-	Error  *Error      `json:"error"`
-	ID     string      `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+	ID     string          `json:"id"`
 }
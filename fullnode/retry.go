@@ -0,0 +1,121 @@
+package fullnode
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	ENV_RPC_MAX_ATTEMPTS  = "RPC_MAX_ATTEMPTS"
+	ENV_RPC_BASE_DELAY    = "RPC_BASE_DELAY"
+	ENV_RPC_MAX_DELAY     = "RPC_MAX_DELAY"
+	ENV_RPC_CALL_TIMEOUT  = "RPC_CALL_TIMEOUT"
+	ENV_BREAKER_THRESHOLD = "RPC_BREAKER_THRESHOLD"
+	ENV_BREAKER_COOLDOWN  = "RPC_BREAKER_COOLDOWN"
+
+	// bitcoind error codes which go away by themselves, see src/rpc/protocol.h
+	RPC_IN_WARMUP                  = -28
+	RPC_CLIENT_IN_INITIAL_DOWNLOAD = -10
+)
+
+// RetryPolicy controls how transient failures of a call are retried and when the circuit breaker opens
+type RetryPolicy struct {
+	MaxAttempts      int           // attempts per call, including the first one
+	BaseDelay        time.Duration // delay before the first retry, doubled on every retry
+	MaxDelay         time.Duration // upper bound of the delay between retries
+	CallTimeout      time.Duration // timeout of a single attempt
+	BreakerThreshold int           // consecutive failed calls which open the circuit
+	BreakerCooldown  time.Duration // how long the circuit stays open before a call is let through again
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      5,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         30 * time.Second,
+		CallTimeout:      time.Minute,
+		BreakerThreshold: 3,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// RetryPolicyFromEnv overrides the defaults with the RPC_* environment variables which are set
+func RetryPolicyFromEnv() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = IntFromEnv(ENV_RPC_MAX_ATTEMPTS, policy.MaxAttempts)
+	policy.BaseDelay = durationFromEnv(ENV_RPC_BASE_DELAY, policy.BaseDelay)
+	policy.MaxDelay = durationFromEnv(ENV_RPC_MAX_DELAY, policy.MaxDelay)
+	policy.CallTimeout = durationFromEnv(ENV_RPC_CALL_TIMEOUT, policy.CallTimeout)
+	policy.BreakerThreshold = IntFromEnv(ENV_BREAKER_THRESHOLD, policy.BreakerThreshold)
+	policy.BreakerCooldown = durationFromEnv(ENV_BREAKER_COOLDOWN, policy.BreakerCooldown)
+	return policy
+}
+
+// IntFromEnv returns the positive integer set in `key`, `fallback` when it isn't set or isn't valid
+func IntFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("[warning] invalid %s %q, using %d\n", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("[warning] invalid %s %q, using %s\n", key, value, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// backoff returns the delay before retry number `retry` (starting at 1), with jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// pick a delay in [delay/2, delay) so that callers don't retry in lockstep
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// IsTransient tells whether a failed call is worth retrying: the node is unreachable, overloaded or still warming up
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		switch transportErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+			return false
+		}
+		return true
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == RPC_IN_WARMUP || rpcErr.Code == RPC_CLIENT_IN_INITIAL_DOWNLOAD
+	}
+	return false
+}
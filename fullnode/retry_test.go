@@ -0,0 +1,112 @@
+package fullnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"connection refused", &TransportError{Err: errNodeDown}, true},
+		{"service unavailable", &TransportError{StatusCode: http.StatusServiceUnavailable, Err: errNodeDown}, true},
+		{"unauthorized", &TransportError{StatusCode: http.StatusUnauthorized, Err: errNodeDown}, false},
+		{"forbidden", &TransportError{StatusCode: http.StatusForbidden, Err: errNodeDown}, false},
+		{"not found", &TransportError{StatusCode: http.StatusNotFound, Err: errNodeDown}, false},
+		{"warming up", &RPCError{Code: RPC_IN_WARMUP, Message: "Loading block index..."}, true},
+		{"initial download", &RPCError{Code: RPC_CLIENT_IN_INITIAL_DOWNLOAD, Message: "in initial download"}, true},
+		{"invalid parameter", &RPCError{Code: RPC_INVALID_PARAMETER, Message: "Block height out of range"}, false},
+		{"wrapped rpc error", fmt.Errorf("failed to get block: %w", &RPCError{Code: RPC_IN_WARMUP}), true},
+		{"decode error", &DecodeError{Err: errors.New("unexpected end of JSON input")}, false},
+		{"cancelled", &TransportError{Err: context.Canceled}, false},
+		{"other error", errors.New("something else"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if transient := IsTransient(test.err); transient != test.transient {
+				t.Errorf("expected transient to be %t, got %t", test.transient, transient)
+			}
+		})
+	}
+}
+
+func newTestServer(maxAttempts int, threshold int) server {
+	policy := RetryPolicy{
+		MaxAttempts:      maxAttempts,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         time.Millisecond,
+		CallTimeout:      time.Second,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  time.Minute,
+	}
+	return server{policy: policy, breaker: newCircuitBreaker(policy.BreakerThreshold, policy.BreakerCooldown)}
+}
+
+func TestWithRetryRetriesTransientErrors(t *testing.T) {
+	s := newTestServer(3, 1)
+	attempts := 0
+	err := s.withRetry(context.Background(), "test", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return &TransportError{Err: errNodeDown}
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d attempts", err, attempts)
+	}
+	expectState(t, s.breaker, CircuitClosed)
+}
+
+func TestWithRetryCountsExhaustedRetries(t *testing.T) {
+	s := newTestServer(2, 1)
+	err := s.withRetry(context.Background(), "test", func(ctx context.Context) error {
+		return &TransportError{Err: errNodeDown}
+	})
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("expected the transport error, got %v", err)
+	}
+	expectState(t, s.breaker, CircuitOpen)
+	if err := s.withRetry(context.Background(), "test", func(ctx context.Context) error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestWithRetryIgnoresCancelledCalls(t *testing.T) {
+	s := newTestServer(5, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := s.withRetry(ctx, "test", func(ctx context.Context) error {
+		// the client goes away while the node is down
+		cancel()
+		return &TransportError{Err: errNodeDown}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	expectState(t, s.breaker, CircuitClosed)
+	if health := s.breaker.health(); health.ConsecutiveFailures != 0 {
+		t.Fatalf("expected no failure to be recorded, got %d", health.ConsecutiveFailures)
+	}
+}
+
+func TestWithRetryIgnoresCancellationDuringBackoff(t *testing.T) {
+	s := newTestServer(5, 1)
+	s.policy.BaseDelay, s.policy.MaxDelay = time.Minute, time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.withRetry(ctx, "test", func(ctx context.Context) error {
+		return &TransportError{Err: errNodeDown}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	expectState(t, s.breaker, CircuitClosed)
+}
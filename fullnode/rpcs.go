@@ -2,11 +2,13 @@ package fullnode
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
+	"time"
 )
 
 type RpcMethods string
@@ -19,20 +21,48 @@ const (
 	RpcMethodsGetBlockHash     RpcMethods = "getblockhash"
 	RpcMethodsGetBlock         RpcMethods = "getblock"
 	RpcMethodsGetBlockCount    RpcMethods = "getblockcount"
+	RpcMethodsGetRawMempool    RpcMethods = "getrawmempool"
+	RpcMethodsGetRawTx         RpcMethods = "getrawtransaction"
+	RpcMethodsGetTxOut         RpcMethods = "gettxout"
+	RpcMethodsGetBlockHeader   RpcMethods = "getblockheader"
+	RpcMethodsSendRawTx        RpcMethods = "sendrawtransaction"
+	RpcMethodsTestMempool      RpcMethods = "testmempoolaccept"
+	RpcMethodsEstimateSmartFee RpcMethods = "estimatesmartfee"
+	RpcMethodsGetMempoolInfo   RpcMethods = "getmempoolinfo"
+
+	// BlockVerbosityTransactions returns the block with every transaction decoded
+	BlockVerbosityTransactions = 2
+	// BlockVerbosityPrevouts additionally decodes the output spent by every input,
+	// which is what a rollback needs to restore spent coins (bitcoind >= 23.0)
+	BlockVerbosityPrevouts = 3
 )
 
 type server struct {
 	rpcUrl     string
 	httpClient *http.Client
+	policy     RetryPolicy
+	breaker    *circuitBreaker
+	batchSize  int
 }
 
 func New(rpcUrl string) Interface {
+	return NewWithRetryPolicy(rpcUrl, RetryPolicyFromEnv())
+}
+
+func NewWithRetryPolicy(rpcUrl string, policy RetryPolicy) Interface {
 	return &server{
 		rpcUrl:     rpcUrl,
 		httpClient: &http.Client{},
+		policy:     policy,
+		breaker:    newCircuitBreaker(policy.BreakerThreshold, policy.BreakerCooldown),
+		batchSize:  IntFromEnv(ENV_RPC_BATCH_SIZE, DEFAULT_BATCH_SIZE),
 	}
 }
 
+func (s server) Health() Health {
+	return s.breaker.health()
+}
+
 type Payload struct {
 	JsonRpc string        `json:"jsonrpc"`
 	ID      string        `json:"id"`
@@ -40,92 +70,150 @@ type Payload struct {
 	Params  []interface{} `json:"params"`
 }
 
-func (s server) GetBlockAtHeight(height int) *Block {
+func (s server) GetBlockAtHeight(ctx context.Context, height int) (*Block, error) {
+	blockHash, err := s.GetBlockHash(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetBlock(ctx, blockHash)
+}
+
+func (s server) GetBlockHash(ctx context.Context, height int) (string, error) {
 	payload := &Payload{
 		Method: RpcMethodsGetBlockHash,
 		Params: []interface{}{height},
 	}
 
-	result := s.rpcCall(payload)
-	if result == nil {
-		log.Println("[error] rpc call to get block hash has failed!")
-		return nil
-	}
-
-	blockHash, ok := result.(string)
-	if !ok {
-		log.Println("[error] failed to cast result to string")
-		return nil
+	var blockHash string
+	if err := s.rpcCall(ctx, payload, &blockHash); err != nil {
+		// bitcoind answers "Block height out of range" for heights above the tip
+		return "", notFoundOnCode(err, RPC_INVALID_PARAMETER)
 	}
 	log.Printf("[debug] block hash at height %d is %s\n", height, blockHash)
 
-	return s.GetBlock(blockHash)
+	return blockHash, nil
 }
 
-func (s server) GetBestBlock() *Block {
+func (s server) GetBestBlock(ctx context.Context) (*Block, error) {
 	payload := &Payload{
 		Method: RpcMethodsGetBestBlockHash,
 		Params: []interface{}{},
 	}
 
-	result := s.rpcCall(payload)
-	if result == nil {
-		log.Println("[error] rpc call to get best block hash has failed!")
-		return nil
+	var blockHash string
+	if err := s.rpcCall(ctx, payload, &blockHash); err != nil {
+		return nil, err
 	}
 
-	blockHash, ok := result.(string)
-	if !ok {
-		log.Println("[error] failed to case result in to string")
-		return nil
-	}
+	return s.GetBlock(ctx, blockHash)
+}
+
+func (s server) GetBlock(ctx context.Context, hash string) (*Block, error) {
+	return s.getBlockWithVerbosity(ctx, hash, BlockVerbosityTransactions)
+}
 
-	return s.GetBlock(blockHash)
+func (s server) GetBlockWithPrevouts(ctx context.Context, hash string) (*Block, error) {
+	return s.getBlockWithVerbosity(ctx, hash, BlockVerbosityPrevouts)
 }
 
-func (s server) GetBlock(hash string) *Block {
+func (s server) getBlockWithVerbosity(ctx context.Context, hash string, verbosity int) (*Block, error) {
 	payload := &Payload{
 		Method: RpcMethodsGetBlock,
-		Params: []interface{}{hash, 2},
+		Params: []interface{}{hash, verbosity},
 	}
 
-	result := s.rpcCall(payload)
-	if result == nil {
-		log.Println("[error] rpc call to get best block hash has failed!")
-		return nil
+	block := &Block{}
+	if err := s.rpcCall(ctx, payload, block); err != nil {
+		// bitcoind answers "Block not found" for unknown hashes
+		return nil, notFoundOnCode(err, RPC_INVALID_ADDRESS_OR_KEY)
 	}
 
-	block := &Block{}
-	buf := &bytes.Buffer{}
-	json.NewEncoder(buf).Encode(result)
-	json.NewDecoder(buf).Decode(block)
+	return block, nil
+}
 
-	return block
+// GetBlockHeaderHex returns the serialized 80 byte header of the block in hex
+func (s server) GetBlockHeaderHex(ctx context.Context, hash string) (string, error) {
+	payload := &Payload{
+		Method: RpcMethodsGetBlockHeader,
+		Params: []interface{}{hash, false},
+	}
+
+	var header string
+	if err := s.rpcCall(ctx, payload, &header); err != nil {
+		return "", notFoundOnCode(err, RPC_INVALID_ADDRESS_OR_KEY)
+	}
+	return header, nil
 }
 
-func (s server) GetBestBlockHeight() int {
+func (s server) GetBestBlockHeight(ctx context.Context) (int, error) {
 	payload := &Payload{
 		Method: RpcMethodsGetBlockCount,
 		Params: []interface{}{},
 	}
 
-	result := s.rpcCall(payload)
-	if result == nil {
-		log.Println("[error] rpc call to get best block hash has failed!")
-		return -1
+	var height int
+	if err := s.rpcCall(ctx, payload, &height); err != nil {
+		return -1, err
 	}
+	return height, nil
+}
 
-	heightF, ok := result.(float64)
-	if !ok {
-		log.Println("[error] failed to cast result to float64 as result is ", result)
-		return -1
+// rpcCall sends `payload` and decodes the result into `result`, failures are reported as
+// *TransportError, *RPCError or *DecodeError
+func (s server) rpcCall(ctx context.Context, payload *Payload, result interface{}) error {
+	return s.withRetry(ctx, string(payload.Method), func(ctx context.Context) error {
+		return s.call(ctx, payload, result)
+	})
+}
+
+// withRetry runs `fn` with a per-attempt timeout and retries transient failures with backoff.
+// While the circuit breaker is open it fails right away with ErrCircuitOpen, calls cancelled by `ctx` don't count
+// towards opening it
+func (s server) withRetry(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if !s.breaker.allow() {
+		return ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 1; attempt <= s.policy.MaxAttempts; attempt++ {
+		err = s.withTimeout(ctx, fn)
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, which tells nothing about the node
+			s.breaker.abandon()
+			return ctx.Err()
+		}
+		if err == nil || !IsTransient(err) {
+			// the node answered, even if it's with an error
+			s.breaker.success()
+			return err
+		}
+		if attempt == s.policy.MaxAttempts {
+			break
+		}
+
+		delay := s.policy.backoff(attempt)
+		log.Printf("[warning] %s attempt %d/%d failed with error: %s, retrying in %s\n", name, attempt, s.policy.MaxAttempts, err.Error(), delay)
+		select {
+		case <-ctx.Done():
+			s.breaker.abandon()
+			return ctx.Err()
+		case <-time.After(delay):
+		}
 	}
-	return int(heightF)
+
+	s.breaker.failure(err)
+	return err
 }
 
-func (s server) rpcCall(payload *Payload) interface{} {
-	url := s.rpcUrl
+func (s server) withTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, s.policy.CallTimeout)
+	defer cancel()
+	return fn(ctx)
+}
 
+// call sends `payload` once
+func (s server) call(ctx context.Context, payload *Payload, result interface{}) error {
 	if payload.JsonRpc == "" {
 		payload.JsonRpc = "1.0"
 	}
@@ -134,44 +222,54 @@ func (s server) rpcCall(payload *Payload) interface{} {
 		payload.ID = "1"
 	}
 
-	marshaled, err := json.Marshal(payload)
+	body, err := s.post(ctx, payload)
 	if err != nil {
-		log.Println("[error] failed to marshal payload with error: ", err.Error())
-		return nil
+		return err
+	}
+
+	response := &Response{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return &DecodeError{Err: err}
 	}
 
-	reader := strings.NewReader(string(marshaled))
+	if response.Error != nil {
+		return response.Error
+	}
 
-	req, err := http.NewRequest(http.MethodPost, url, reader)
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return &DecodeError{Err: err}
+	}
+	return nil
+}
+
+// post sends the JSON encoded `request` and returns the response body
+func (s server) post(ctx context.Context, request interface{}) ([]byte, error) {
+	marshaled, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.rpcUrl, bytes.NewReader(marshaled))
 	if err != nil {
-		log.Println("[error] failed to initialize a new request with error: ", err.Error())
-		return nil
+		return nil, err
 	}
 	req.Header.Add(KEY_CONTENT_TYPE, CONTENT_TYPE_TEXT_PLAIN)
 
 	res, err := s.httpClient.Do(req)
 	if err != nil {
-		log.Println("[error] failed to send post request with error: ", err.Error())
-		return nil
+		return nil, &TransportError{Err: err}
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		log.Println("[error] failed to read body with error: ", err.Error())
-		return nil
+		return nil, &TransportError{StatusCode: res.StatusCode, Err: err}
 	}
 
-	response := &Response{}
-	if err := json.Unmarshal(body, &response); err != nil {
-		log.Println("[error] failed to unmarshal response with error: ", err.Error())
-		return nil
+	// bitcoind reports rpc errors with a non-200 status and a JSON-RPC body,
+	// anything else which isn't JSON (e.g. 401 on bad credentials) is a transport problem
+	if res.StatusCode != http.StatusOK && !json.Valid(body) {
+		return nil, &TransportError{StatusCode: res.StatusCode, Err: errors.New(http.StatusText(res.StatusCode))}
 	}
-
-	if response.Error != nil {
-		log.Printf("[warning] unsuccessful request:\terror code: %d\tmessage: %s\n", response.Error.Code, response.Error.Message)
-		return nil
-	}
-
-	return response.Result
+	return body, nil
 }
@@ -0,0 +1,39 @@
+package fullnode
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// GetTxOuts looks up `outpoints` in the node's UTXO set in one batch, leaving mempool spends aside.
+// The result is in the same order, with nil for outputs which are spent or unknown
+func (s server) GetTxOuts(ctx context.Context, outpoints []*Outpoint) ([]*UnspentTxOut, error) {
+	if len(outpoints) == 0 {
+		return nil, nil
+	}
+
+	var payloads []*Payload
+	for _, outpoint := range outpoints {
+		payloads = append(payloads, &Payload{
+			Method: RpcMethodsGetTxOut,
+			Params: []interface{}{outpoint.Txid, outpoint.Vout, false},
+		})
+	}
+
+	responses, err := s.rpcBatchCall(ctx, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	txOuts := make([]*UnspentTxOut, len(responses))
+	for index, response := range responses {
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		// gettxout answers null for spent outputs
+		if err := json.Unmarshal(response.Result, &txOuts[index]); err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+	}
+	return txOuts, nil
+}
@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ABMatrix/bitcoin-utxo-ms/api"
+	"github.com/ABMatrix/bitcoin-utxo-ms/electrum"
+	"github.com/ABMatrix/bitcoin-utxo-ms/fees"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mempool"
 	"github.com/ABMatrix/bitcoin-utxo-ms/middleware"
+	"github.com/ABMatrix/bitcoin-utxo-ms/notifier"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
 
 	"github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
 
@@ -27,6 +39,22 @@ const (
 	ENV_UTXO_COLLECTION_NAME = "UTXO_COLLECTION_NAME"
 	ENV_MONGO_URI            = "MONGO_URI"
 	ENV_PORT                 = "PORT"
+	// ENV_BTC_ZMQ_BLOCK_URI is optional, bitcoind's -zmqpubhashblock (or -zmqpubrawblock) endpoint
+	ENV_BTC_ZMQ_BLOCK_URI = "BTC_ZMQ_BLOCK_URI"
+	// ENV_MEMPOOL_TRACKING set to "true" records unconfirmed outputs and pending spends
+	ENV_MEMPOOL_TRACKING = "MEMPOOL_TRACKING"
+	// ENV_ELECTRUM_TCP_LISTEN and ENV_ELECTRUM_TLS_LISTEN are optional addresses, e.g. ":50001", to serve the
+	// Electrum protocol on; TLS needs ENV_ELECTRUM_TLS_CERT_FILE and ENV_ELECTRUM_TLS_KEY_FILE
+	ENV_ELECTRUM_TCP_LISTEN    = "ELECTRUM_TCP_LISTEN"
+	ENV_ELECTRUM_TLS_LISTEN    = "ELECTRUM_TLS_LISTEN"
+	ENV_ELECTRUM_TLS_CERT_FILE = "ELECTRUM_TLS_CERT_FILE"
+	ENV_ELECTRUM_TLS_KEY_FILE  = "ELECTRUM_TLS_KEY_FILE"
+
+	CMD_REWIND         = "rewind"
+	CMD_VERIFY_AMOUNTS = "verify-amounts"
+
+	// SHUTDOWN_TIMEOUT bounds how long in-flight HTTP requests are drained on shutdown
+	SHUTDOWN_TIMEOUT = 30 * time.Second
 )
 
 func main() {
@@ -55,7 +83,14 @@ func main() {
 		log.Fatalln(ENV_PORT, " is unset!")
 	}
 
-	ctx := context.Background()
+	network, err := script.NetworkFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// SIGTERM is what docker-compose sends on `down`/`stop`
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// initialize mongodb
 	clientOptions := options.Client().ApplyURI(mongoUri)
@@ -75,20 +110,165 @@ func main() {
 
 	btcServer := fullnode.New(btcUri)
 	mongoServer := _mongo.New(mongoCli, btcDatabase, utxoCollection)
-	syncer := synchronizer.New(mongoServer, btcServer)
-	syncer.Start(ctx) // this is a blocking process!!
+	var blockNotifier notifier.Interface
+	if zmqUri := os.Getenv(ENV_BTC_ZMQ_BLOCK_URI); zmqUri != "" {
+		blockNotifier = notifier.New(zmqUri)
+	}
+	syncer := synchronizer.New(mongoServer, btcServer, blockNotifier, network)
+
+	if len(os.Args) > 1 && os.Args[1] == CMD_REWIND {
+		rewind(ctx, syncer, os.Args[2:])
+		disconnect(mongoCli)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == CMD_VERIFY_AMOUNTS {
+		verifyAmounts(ctx, syncer, os.Args[2:])
+		disconnect(mongoCli)
+		return
+	}
+
+	if blockNotifier != nil {
+		blockNotifier.Start(ctx)
+	}
+	syncer.Start(ctx) // syncing runs in the background, the API serves whatever has been indexed so far
+	var mempoolWatcher mempool.Interface
+	if os.Getenv(ENV_MEMPOOL_TRACKING) == "true" {
+		mempoolWatcher = mempool.New(mongoServer, btcServer, syncer, network)
+		mempoolWatcher.Start(ctx)
+	}
+	electrumServer := serveElectrum(ctx, func() electrum.Interface {
+		return electrum.New(mongoServer, btcServer, syncer, mempoolWatcher)
+	})
 
 	// initialize gin web server
 	router := gin.Default()
 	router.Use(middleware.Cors())
 
-	apiServer := api.New(mongoCli, btcDatabase, utxoCollection)
+	apiServer := api.New(mongoCli, btcDatabase, utxoCollection, mongoServer, syncer, btcServer, mempoolWatcher, fees.New(btcServer), network)
+	router.GET("/status", apiServer.StatusHandler)
+	router.GET("/fees", middleware.IndexedHeight(apiServer.IndexedHeight), apiServer.FeesHandler)
 	utxoQuery := router.Group("/utxo")
+	utxoQuery.Use(middleware.IndexedHeight(apiServer.IndexedHeight))
 	utxoQuery.POST("list", apiServer.ListHandler)
+	utxoQuery.POST("select", apiServer.SelectHandler)
+	opReturnQuery := router.Group("/op_return")
+	opReturnQuery.Use(middleware.IndexedHeight(apiServer.IndexedHeight))
+	opReturnQuery.POST("search", apiServer.OpReturnSearchHandler)
+	scriptHashQuery := router.Group("/scripthash")
+	scriptHashQuery.Use(middleware.IndexedHeight(apiServer.IndexedHeight))
+	scriptHashQuery.GET(":hash/unspent", apiServer.ScriptHashUnspentHandler)
+	psbtQuery := router.Group("/psbt")
+	psbtQuery.Use(middleware.IndexedHeight(apiServer.IndexedHeight))
+	psbtQuery.POST("create", apiServer.PsbtCreateHandler)
+	txQuery := router.Group("/tx")
+	txQuery.POST("broadcast", apiServer.BroadcastHandler)
+	addressQuery := router.Group("/address")
+	addressQuery.Use(middleware.IndexedHeight(apiServer.IndexedHeight))
+	addressQuery.GET(":address/balance", apiServer.AddressBalanceHandler)
+	addressQuery.POST("balance", apiServer.BalanceHandler)
 
 	httpServer := &http.Server{
 		Addr:    fmt.Sprintf(":%s", port),
 		Handler: router,
 	}
-	log.Fatalln(httpServer.ListenAndServe()) // TODO: change it to HTTPS server
+	go func() {
+		// TODO: change it to HTTPS server
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalln(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("[debug] shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Println("[error] failed to shut down http server with error: ", err)
+	}
+
+	syncer.Stop()
+	if mempoolWatcher != nil {
+		mempoolWatcher.Stop()
+	}
+	syncer.Wait()
+	if mempoolWatcher != nil {
+		mempoolWatcher.Wait()
+	}
+	if electrumServer != nil {
+		// sessions may still be recording a broadcast transaction
+		electrumServer.Wait()
+	}
+	disconnect(mongoCli)
+	log.Println("[debug] bye")
+}
+
+// serveElectrum opens the Electrum listeners which are configured, it exits when one can't be opened. It returns
+// the server, nil when no listener is configured
+func serveElectrum(ctx context.Context, newServer func() electrum.Interface) electrum.Interface {
+	var listeners []net.Listener
+	if addr := os.Getenv(ENV_ELECTRUM_TCP_LISTEN); addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalln("[error] failed to listen for electrum connections with error: ", err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if addr := os.Getenv(ENV_ELECTRUM_TLS_LISTEN); addr != "" {
+		certificate, err := tls.LoadX509KeyPair(os.Getenv(ENV_ELECTRUM_TLS_CERT_FILE), os.Getenv(ENV_ELECTRUM_TLS_KEY_FILE))
+		if err != nil {
+			log.Fatalln("[error] failed to load electrum TLS certificate with error: ", err)
+		}
+		listener, err := tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{certificate}})
+		if err != nil {
+			log.Fatalln("[error] failed to listen for electrum TLS connections with error: ", err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	electrumServer := newServer()
+	electrumServer.Start(ctx, listeners...)
+	return electrumServer
+}
+
+func disconnect(mongoCli *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := mongoCli.Disconnect(ctx); err != nil {
+		log.Println("[error] failed to disconnect from mongodb with error: ", err)
+	}
+}
+
+// rewind reverts the applied blocks down to `--to-height` and exits
+func rewind(ctx context.Context, syncer synchronizer.Interface, args []string) {
+	rewindFlags := flag.NewFlagSet(CMD_REWIND, flag.ExitOnError)
+	toHeight := rewindFlags.Int("to-height", -1, "height of the last block to keep")
+	rewindFlags.Parse(args)
+	if *toHeight < 0 {
+		log.Fatalln("[error] --to-height is required")
+	}
+
+	if err := syncer.Rewind(ctx, *toHeight); err != nil {
+		log.Fatalln("[error] failed to rewind with error: ", err)
+	}
+	log.Println("[debug] rewound to height ", *toHeight)
+}
+
+// verifyAmounts reports the stored amounts which disagree with the node, and fixes them with `--repair`
+func verifyAmounts(ctx context.Context, syncer synchronizer.Interface, args []string) {
+	verifyFlags := flag.NewFlagSet(CMD_VERIFY_AMOUNTS, flag.ExitOnError)
+	repair := verifyFlags.Bool("repair", false, "overwrite the amounts which disagree with the node")
+	verifyFlags.Parse(args)
+
+	report, err := syncer.VerifyAmounts(ctx, *repair)
+	if report != nil {
+		log.Printf("[debug] checked %d UTXOs: %d mismatched, %d repaired, %d missing from the node\n",
+			report.Checked, report.Mismatched, report.Repaired, report.Missing)
+	}
+	if err != nil {
+		log.Fatalln("[error] failed to verify amounts with error: ", err)
+	}
 }
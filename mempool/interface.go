@@ -0,0 +1,12 @@
+package mempool
+
+import "context"
+
+//go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=mempool
+type Interface interface {
+	Start(ctx context.Context)
+	Stop()
+	Wait()
+	OnChange(fn func())
+	Track(ctx context.Context, txid string) error
+}
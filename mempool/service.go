@@ -0,0 +1,255 @@
+package mempool
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
+)
+
+const (
+	POLL_INTERVAL = 10 * time.Second
+	// FETCH_CHUNK is how many new transactions are fetched and saved at once
+	FETCH_CHUNK = 100
+)
+
+// server mirrors bitcoind's mempool into the unconfirmed outputs and pending spends collections
+type server struct {
+	mongoServer mongo.Interface
+	fullnode    fullnode.Interface
+	network     *script.Network
+	listeners   *listeners
+	tracked     *tracked
+	running     *sync.WaitGroup
+	stop        chan struct{}
+	stopOnce    *sync.Once
+	// tipChanged triggers a poll as soon as a block is applied, so its transactions don't stay recorded as unconfirmed
+	tipChanged chan struct{}
+}
+
+type listeners struct {
+	mu  sync.RWMutex
+	fns []func()
+}
+
+// tracked are the transactions recorded by Track since the last poll
+type tracked struct {
+	mu    sync.Mutex
+	txids []string
+}
+
+func New(m mongo.Interface, f fullnode.Interface, syncer synchronizer.Interface, network *script.Network) Interface {
+	s := &server{
+		mongoServer: m,
+		fullnode:    f,
+		network:     network,
+		listeners:   &listeners{},
+		tracked:     &tracked{},
+		running:     &sync.WaitGroup{},
+		stop:        make(chan struct{}),
+		stopOnce:    &sync.Once{},
+		tipChanged:  make(chan struct{}, 1),
+	}
+
+	syncer.OnTipChanged(func(height int, hash string) {
+		// never blocks, a pending signal already covers the new tip
+		select {
+		case s.tipChanged <- struct{}{}:
+		default:
+		}
+	})
+	return s
+}
+
+// OnChange registers `fn` to be called after a poll changed the recorded mempool, it must not block
+func (s server) OnChange(fn func()) {
+	s.listeners.mu.Lock()
+	defer s.listeners.mu.Unlock()
+	s.listeners.fns = append(s.listeners.fns, fn)
+}
+
+func (s server) notifyChange() {
+	s.listeners.mu.RLock()
+	defer s.listeners.mu.RUnlock()
+	for _, fn := range s.listeners.fns {
+		fn()
+	}
+}
+
+// Track records a transaction which just entered the node's mempool without waiting for the next poll, which then
+// drops it like any other if it already left the mempool
+func (s server) Track(ctx context.Context, txid string) error {
+	transactions, err := s.fullnode.GetRawTransactions(ctx, []string{txid})
+	if err != nil {
+		return err
+	}
+	if len(transactions) == 0 {
+		// already confirmed or evicted, the next poll sorts it out
+		return nil
+	}
+	if err := s.mongoServer.AddMempoolTransactions(ctx, []*mongo.MempoolTransaction{s.mempoolTransaction(transactions[0])}); err != nil {
+		return err
+	}
+	s.tracked.mu.Lock()
+	s.tracked.txids = append(s.tracked.txids, txid)
+	s.tracked.mu.Unlock()
+	s.notifyChange()
+	return nil
+}
+
+// Start polls the mempool in the background, and after every new tip, until ctx is done or Stop is called
+func (s server) Start(ctx context.Context) {
+	s.running.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		select {
+		case <-s.stop:
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer s.running.Done()
+		s.run(ctx)
+	}()
+}
+
+// Stop asks the polling to stop, the poll in progress is abandoned
+func (s server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Wait blocks until the polling has stopped
+func (s server) Wait() {
+	s.running.Wait()
+}
+
+func (s server) run(ctx context.Context) {
+	// picks up where the previous run left off, transactions gone in the meantime are dropped by the first poll
+	known := map[string]bool{}
+	txids, err := s.mongoServer.GetMempoolTxids(ctx)
+	if err != nil {
+		log.Println("[error] failed to get recorded mempool transactions with error: ", err.Error())
+	}
+	for _, txid := range txids {
+		known[txid] = true
+	}
+
+	ticker := time.NewTicker(POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		s.poll(ctx, known)
+		select {
+		case <-ctx.Done():
+			log.Println("[debug] mempool watcher stopped")
+			return
+		case <-ticker.C:
+		case <-s.tipChanged:
+		}
+	}
+}
+
+// poll diffs the node's mempool against `known`: transactions which left it, because they were confirmed,
+// replaced or evicted, are dropped and new ones are recorded
+func (s server) poll(ctx context.Context, known map[string]bool) {
+	s.tracked.mu.Lock()
+	for _, txid := range s.tracked.txids {
+		known[txid] = true
+	}
+	s.tracked.txids = nil
+	s.tracked.mu.Unlock()
+
+	txids, err := s.fullnode.GetRawMempool(ctx)
+	if err != nil {
+		log.Println("[warning] failed to get mempool with error: ", err.Error())
+		return
+	}
+
+	current := make(map[string]bool, len(txids))
+	for _, txid := range txids {
+		current[txid] = true
+	}
+
+	var gone []string
+	for txid := range known {
+		if !current[txid] {
+			gone = append(gone, txid)
+		}
+	}
+	if len(gone) > 0 {
+		if err := s.mongoServer.RemoveMempoolTransactions(ctx, gone); err != nil {
+			log.Println("[error] failed to remove mempool transactions with error: ", err.Error())
+			return
+		}
+		for _, txid := range gone {
+			delete(known, txid)
+		}
+	}
+
+	var added []string
+	for _, txid := range txids {
+		if !known[txid] {
+			added = append(added, txid)
+		}
+	}
+	for start := 0; start < len(added); start += FETCH_CHUNK {
+		end := start + FETCH_CHUNK
+		if end > len(added) {
+			end = len(added)
+		}
+
+		// transactions evicted since getrawmempool are left out and picked up as gone next time, if at all
+		transactions, err := s.fullnode.GetRawTransactions(ctx, added[start:end])
+		if err != nil {
+			log.Println("[warning] failed to get mempool transactions with error: ", err.Error())
+			return
+		}
+
+		var changes []*mongo.MempoolTransaction
+		for _, transaction := range transactions {
+			changes = append(changes, s.mempoolTransaction(transaction))
+		}
+		if err := s.mongoServer.AddMempoolTransactions(ctx, changes); err != nil {
+			log.Println("[error] failed to save mempool transactions with error: ", err.Error())
+			return
+		}
+		for _, transaction := range transactions {
+			known[transaction.Txid] = true
+		}
+	}
+	if len(gone) > 0 || len(added) > 0 {
+		log.Printf("[debug] mempool updated, %d transactions dropped and %d seen\n", len(gone), len(added))
+		s.notifyChange()
+	}
+}
+
+func (s server) mempoolTransaction(transaction *fullnode.Transaction) *mongo.MempoolTransaction {
+	changes := &mongo.MempoolTransaction{TxID: transaction.Txid}
+	for _, txout := range transaction.TxOuts {
+		if txout == nil || !mongo.IsSpendable(txout) {
+			continue
+		}
+		utxo := mongo.NewUTXO(s.network, transaction.Txid, txout, 0, false)
+		utxo.Unconfirmed = true
+		changes.Outputs = append(changes.Outputs, utxo)
+	}
+	for _, txin := range transaction.TxIns {
+		if txin == nil || txin.Coinbase != "" {
+			continue
+		}
+		changes.Spends = append(changes.Spends, &mongo.PendingSpend{
+			TxID:    txin.Txid,
+			Vout:    int(txin.Vout),
+			SpentBy: transaction.Txid,
+		})
+	}
+	return changes
+}
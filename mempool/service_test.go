@@ -0,0 +1,68 @@
+package mempool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fmocks "github.com/ABMatrix/bitcoin-utxo-ms/fullnode/mocks"
+	mmocks "github.com/ABMatrix/bitcoin-utxo-ms/mongo/mocks"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
+	smocks "github.com/ABMatrix/bitcoin-utxo-ms/synchronizer/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+func TestNewTipDropsConfirmedTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mmocks.NewMockInterface(ctrl)
+	f := fmocks.NewMockInterface(ctrl)
+	syncer := smocks.NewMockInterface(ctrl)
+
+	var onTip synchronizer.TipListener
+	syncer.EXPECT().OnTipChanged(gomock.Any()).Do(func(fn synchronizer.TipListener) { onTip = fn })
+	m.EXPECT().GetMempoolTxids(gomock.Any()).Return([]string{"confirmed"}, nil)
+
+	polled := make(chan struct{}, 2)
+	gomock.InOrder(
+		f.EXPECT().GetRawMempool(gomock.Any()).DoAndReturn(func(ctx context.Context) ([]string, error) {
+			polled <- struct{}{}
+			return []string{"confirmed"}, nil
+		}),
+		f.EXPECT().GetRawMempool(gomock.Any()).DoAndReturn(func(ctx context.Context) ([]string, error) {
+			polled <- struct{}{}
+			return nil, nil
+		}),
+	)
+	removed := make(chan []string, 1)
+	m.EXPECT().RemoveMempoolTransactions(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, txids []string) error {
+		removed <- txids
+		return nil
+	})
+
+	w := New(m, f, syncer, script.Mainnet)
+	w.Start(context.Background())
+	<-polled
+	// the block confirming the transaction was applied, well before the next scheduled poll
+	onTip(1, "hash")
+	select {
+	case txids := <-removed:
+		if len(txids) != 1 || txids[0] != "confirmed" {
+			t.Fatalf("unexpected removed transactions %v", txids)
+		}
+	case <-time.After(POLL_INTERVAL / 2):
+		t.Fatal("the new tip didn't trigger a poll")
+	}
+
+	w.Stop()
+	done := make(chan struct{})
+	go func() {
+		w.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher didn't stop")
+	}
+}
@@ -0,0 +1,155 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	KEY_MATCH    = "$match"
+	KEY_GROUP    = "$group"
+	KEY_SUM      = "$sum"
+	KEY_COND     = "$cond"
+	KEY_AND      = "$and"
+	KEY_TOTAL    = "total"
+	KEY_COUNT    = "count"
+	KEY_IMMATURE = "immature"
+
+	// COINBASE_MATURITY is the number of blocks after which a coinbase output can be spent
+	COINBASE_MATURITY = 100
+)
+
+// GetScriptHashBalance returns the confirmed balance of `scriptHash` and the net change pending in the mempool,
+// the way Electrum's blockchain.scripthash.get_balance reports it
+func (s server) GetScriptHashBalance(ctx context.Context, scriptHash string) (*Balance, error) {
+	filter := bson.M{KEY_SCRIPTHASH: scriptHash}
+	confirmed, err := sumAmounts(ctx, s.collection, filter)
+	if err != nil {
+		return nil, err
+	}
+	received, err := sumAmounts(ctx, s.mempoolCollection, filter)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := s.pendingSpent(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Balance{
+		Confirmed:   confirmed,
+		Unconfirmed: received - sent,
+	}, nil
+}
+
+// GetAddressBalance sums the unspent outputs of `addresses`, including the bare multisig outputs they're keys of,
+// every output counts once. Coinbase outputs which can't be spent in the block after `indexedHeight` are also
+// reported as immature
+func (s server) GetAddressBalance(ctx context.Context, addresses []string, indexedHeight int) (*AddressBalance, error) {
+	in := bson.M{KEY_IN: addresses}
+	filter := bson.M{KEY_OR: []bson.M{{KEY_ADDRESS: in}, {KEY_ADDRESSES: in}}}
+	cur, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: KEY_MATCH, Value: filter}},
+		{{Key: KEY_GROUP, Value: bson.M{
+			KEY_ID:    nil,
+			KEY_TOTAL: bson.M{KEY_SUM: "$" + KEY_AMOUNT},
+			KEY_COUNT: bson.M{KEY_SUM: 1},
+			KEY_IMMATURE: bson.M{KEY_SUM: bson.M{KEY_COND: bson.A{
+				bson.M{KEY_AND: bson.A{"$" + KEY_COINBASE, bson.M{KEY_GT: bson.A{"$" + KEY_HEIGHT, indexedHeight - COINBASE_MATURITY + 1}}}},
+				"$" + KEY_AMOUNT,
+				0,
+			}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var result struct {
+		Total    int64 `bson:"total"`
+		Count    int64 `bson:"count"`
+		Immature int64 `bson:"immature"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&result); err != nil {
+			return nil, err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	received, err := sumAmounts(ctx, s.mempoolCollection, filter)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := s.pendingSpent(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddressBalance{
+		Balance: Balance{
+			Confirmed:   result.Total,
+			Unconfirmed: received - sent,
+		},
+		Immature:            result.Immature,
+		UTXOCount:           result.Count,
+		UnconfirmedReceived: received,
+		UnconfirmedSent:     sent,
+	}, nil
+}
+
+// pendingSpent sums the amounts of the outputs matching `filter` which mempool transactions spend,
+// whether the outputs are confirmed or not
+func (s server) pendingSpent(ctx context.Context, filter bson.M) (int64, error) {
+	var spends []*PendingSpend
+	cur, err := s.spendsCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := cur.All(ctx, &spends); err != nil {
+		return 0, err
+	}
+	if len(spends) == 0 {
+		return 0, nil
+	}
+
+	var keys []bson.M
+	for _, spend := range spends {
+		keys = append(keys, bson.M{KEY_TXID: spend.TxID, KEY_VOUT: spend.Vout})
+	}
+	var sent int64
+	for _, collection := range []*mongo.Collection{s.collection, s.mempoolCollection} {
+		amount, err := sumAmounts(ctx, collection, bson.M{KEY_OR: keys})
+		if err != nil {
+			return 0, err
+		}
+		sent += amount
+	}
+	return sent, nil
+}
+
+func sumAmounts(ctx context.Context, collection *mongo.Collection, filter bson.M) (int64, error) {
+	cur, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: KEY_MATCH, Value: filter}},
+		{{Key: KEY_GROUP, Value: bson.M{KEY_ID: nil, KEY_TOTAL: bson.M{KEY_SUM: "$" + KEY_AMOUNT}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var result struct {
+		Total int64 `bson:"total"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Total, cur.Err()
+}
@@ -10,6 +10,19 @@ import (
 type Interface interface {
 	InsertMany(ctx context.Context, utxos []*UTXO) error
 	ListCoinsForAddress(ctx context.Context, address string) ([]*UTXO, error)
-	GetMaxHeight(ctx context.Context) int
+	GetMaxHeight(ctx context.Context) (int, error)
 	DeleteMany(ctx context.Context, uniqueKeys []bson.M) error
+	GetBlockHeader(ctx context.Context, height int) (*BlockHeader, error)
+	GetSyncState(ctx context.Context) (*SyncState, error)
+	GetUndoEntry(ctx context.Context, height int, hash string) (*UndoEntry, error)
+	ApplyBlock(ctx context.Context, changes *BlockChanges, state *SyncState) error
+	RevertBlock(ctx context.Context, height int, restoreUtxos []*UTXO, state *SyncState) error
+	ListUnspentByScriptHash(ctx context.Context, scriptHash string) ([]*UTXO, error)
+	GetScriptHashBalance(ctx context.Context, scriptHash string) (*Balance, error)
+	GetAddressBalance(ctx context.Context, addresses []string, indexedHeight int) (*AddressBalance, error)
+	ScanUtxos(ctx context.Context, batchSize int, fn func(utxos []*UTXO) error) error
+	UpdateAmounts(ctx context.Context, utxos []*UTXO) error
+	GetMempoolTxids(ctx context.Context) ([]string, error)
+	AddMempoolTransactions(ctx context.Context, transactions []*MempoolTransaction) error
+	RemoveMempoolTransactions(ctx context.Context, txids []string) error
 }
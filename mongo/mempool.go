@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MEMPOOL_QUERY_CHUNK bounds the number of keys sent in a single $or or $in query
+const MEMPOOL_QUERY_CHUNK = 1000
+
+// GetMempoolTxids returns the ids of every transaction with unconfirmed outputs or pending spends
+func (s server) GetMempoolTxids(ctx context.Context) ([]string, error) {
+	created, err := s.mempoolCollection.Distinct(ctx, KEY_TXID, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	spending, err := s.spendsCollection.Distinct(ctx, KEY_SPENT_BY, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var txids []string
+	for _, value := range append(created, spending...) {
+		txid, ok := value.(string)
+		if !ok || seen[txid] {
+			continue
+		}
+		seen[txid] = true
+		txids = append(txids, txid)
+	}
+	return txids, nil
+}
+
+// AddMempoolTransactions records the outputs and spends of unconfirmed transactions. Mempool data is
+// rebuilt from the node at any time, so the writes are idempotent but not transactional
+func (s server) AddMempoolTransactions(ctx context.Context, transactions []*MempoolTransaction) error {
+	var outputModels, spendModels []mongo.WriteModel
+	for _, transaction := range transactions {
+		for _, utxo := range transaction.Outputs {
+			outputModels = append(outputModels, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{KEY_TXID: utxo.TxID, KEY_VOUT: utxo.Vout}).
+				SetReplacement(utxo).
+				SetUpsert(true))
+		}
+		for _, spend := range transaction.Spends {
+			// a replacement transaction takes over the outpoint from the one it replaces
+			spendModels = append(spendModels, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{KEY_TXID: spend.TxID, KEY_VOUT: spend.Vout}).
+				SetReplacement(spend).
+				SetUpsert(true))
+		}
+	}
+
+	if len(outputModels) > 0 {
+		if _, err := s.mempoolCollection.BulkWrite(ctx, outputModels, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save unconfirmed outputs: %w", err)
+		}
+	}
+	if len(spendModels) > 0 {
+		if _, err := s.spendsCollection.BulkWrite(ctx, spendModels, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save pending spends: %w", err)
+		}
+	}
+	return s.fillSpendAddresses(ctx)
+}
+
+// RemoveMempoolTransactions drops everything recorded for transactions which left the mempool,
+// whether they were confirmed, replaced or evicted
+func (s server) RemoveMempoolTransactions(ctx context.Context, txids []string) error {
+	for start := 0; start < len(txids); start += MEMPOOL_QUERY_CHUNK {
+		end := start + MEMPOOL_QUERY_CHUNK
+		if end > len(txids) {
+			end = len(txids)
+		}
+		chunk := txids[start:end]
+
+		if _, err := s.mempoolCollection.DeleteMany(ctx, bson.M{KEY_TXID: bson.M{KEY_IN: chunk}}); err != nil {
+			return fmt.Errorf("failed to delete unconfirmed outputs: %w", err)
+		}
+		if _, err := s.spendsCollection.DeleteMany(ctx, bson.M{KEY_SPENT_BY: bson.M{KEY_IN: chunk}}); err != nil {
+			return fmt.Errorf("failed to delete pending spends: %w", err)
+		}
+	}
+	return nil
+}
+
+// fillSpendAddresses looks up the address and scripthash of pending spends which don't have them yet. The spent output
+// is either confirmed or created by another mempool transaction, possibly one which wasn't recorded yet
+func (s server) fillSpendAddresses(ctx context.Context) error {
+	var spends []*PendingSpend
+	cur, err := s.spendsCollection.Find(ctx, bson.M{KEY_SCRIPTHASH: ""})
+	if err != nil {
+		return err
+	}
+	if err := cur.All(ctx, &spends); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(spends); start += MEMPOOL_QUERY_CHUNK {
+		end := start + MEMPOOL_QUERY_CHUNK
+		if end > len(spends) {
+			end = len(spends)
+		}
+
+		var keys []bson.M
+		for _, spend := range spends[start:end] {
+			keys = append(keys, bson.M{KEY_TXID: spend.TxID, KEY_VOUT: spend.Vout})
+		}
+
+		var writeModels []mongo.WriteModel
+		for _, collection := range []*mongo.Collection{s.collection, s.mempoolCollection} {
+			var utxos []*UTXO
+			cur, err := collection.Find(ctx, bson.M{KEY_OR: keys})
+			if err != nil {
+				return err
+			}
+			if err := cur.All(ctx, &utxos); err != nil {
+				return err
+			}
+			for _, utxo := range utxos {
+				update := bson.M{KEY_ADDRESS: utxo.Address, KEY_SCRIPTHASH: utxo.ScriptHash}
+				if utxo.ScriptHash == "" {
+					// synced before scripthashes were stored
+					update[KEY_SCRIPTHASH] = scriptHashOf(utxo.Script)
+				}
+				if len(utxo.Addresses) > 0 {
+					update[KEY_ADDRESSES] = utxo.Addresses
+				}
+				writeModels = append(writeModels, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{KEY_TXID: utxo.TxID, KEY_VOUT: utxo.Vout}).
+					SetUpdate(bson.M{KEY_SET: update}))
+			}
+		}
+
+		if len(writeModels) > 0 {
+			if _, err := s.spendsCollection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scriptHashOf(scriptHex string) string {
+	scriptBytes, err := hex.DecodeString(scriptHex)
+	if err != nil {
+		return ""
+	}
+	return script.ScriptHash(scriptBytes)
+}
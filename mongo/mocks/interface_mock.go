@@ -36,6 +36,34 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// AddMempoolTransactions mocks base method.
+func (m *MockInterface) AddMempoolTransactions(ctx context.Context, transactions []*mongo.MempoolTransaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMempoolTransactions", ctx, transactions)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMempoolTransactions indicates an expected call of AddMempoolTransactions.
+func (mr *MockInterfaceMockRecorder) AddMempoolTransactions(ctx, transactions interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMempoolTransactions", reflect.TypeOf((*MockInterface)(nil).AddMempoolTransactions), ctx, transactions)
+}
+
+// ApplyBlock mocks base method.
+func (m *MockInterface) ApplyBlock(ctx context.Context, changes *mongo.BlockChanges, state *mongo.SyncState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyBlock", ctx, changes, state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ApplyBlock indicates an expected call of ApplyBlock.
+func (mr *MockInterfaceMockRecorder) ApplyBlock(ctx, changes, state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyBlock", reflect.TypeOf((*MockInterface)(nil).ApplyBlock), ctx, changes, state)
+}
+
 // DeleteMany mocks base method.
 func (m *MockInterface) DeleteMany(ctx context.Context, uniqueKeys []bson.M) error {
 	m.ctrl.T.Helper()
@@ -50,12 +78,43 @@ func (mr *MockInterfaceMockRecorder) DeleteMany(ctx, uniqueKeys interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockInterface)(nil).DeleteMany), ctx, uniqueKeys)
 }
 
+// GetAddressBalance mocks base method.
+func (m *MockInterface) GetAddressBalance(ctx context.Context, addresses []string, indexedHeight int) (*mongo.AddressBalance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAddressBalance", ctx, addresses, indexedHeight)
+	ret0, _ := ret[0].(*mongo.AddressBalance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAddressBalance indicates an expected call of GetAddressBalance.
+func (mr *MockInterfaceMockRecorder) GetAddressBalance(ctx, addresses, indexedHeight interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddressBalance", reflect.TypeOf((*MockInterface)(nil).GetAddressBalance), ctx, addresses, indexedHeight)
+}
+
+// GetBlockHeader mocks base method.
+func (m *MockInterface) GetBlockHeader(ctx context.Context, height int) (*mongo.BlockHeader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockHeader", ctx, height)
+	ret0, _ := ret[0].(*mongo.BlockHeader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockHeader indicates an expected call of GetBlockHeader.
+func (mr *MockInterfaceMockRecorder) GetBlockHeader(ctx, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockHeader", reflect.TypeOf((*MockInterface)(nil).GetBlockHeader), ctx, height)
+}
+
 // GetMaxHeight mocks base method.
-func (m *MockInterface) GetMaxHeight(ctx context.Context) int {
+func (m *MockInterface) GetMaxHeight(ctx context.Context) (int, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetMaxHeight", ctx)
 	ret0, _ := ret[0].(int)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetMaxHeight indicates an expected call of GetMaxHeight.
@@ -64,6 +123,66 @@ func (mr *MockInterfaceMockRecorder) GetMaxHeight(ctx interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaxHeight", reflect.TypeOf((*MockInterface)(nil).GetMaxHeight), ctx)
 }
 
+// GetMempoolTxids mocks base method.
+func (m *MockInterface) GetMempoolTxids(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMempoolTxids", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMempoolTxids indicates an expected call of GetMempoolTxids.
+func (mr *MockInterfaceMockRecorder) GetMempoolTxids(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMempoolTxids", reflect.TypeOf((*MockInterface)(nil).GetMempoolTxids), ctx)
+}
+
+// GetScriptHashBalance mocks base method.
+func (m *MockInterface) GetScriptHashBalance(ctx context.Context, scriptHash string) (*mongo.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScriptHashBalance", ctx, scriptHash)
+	ret0, _ := ret[0].(*mongo.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScriptHashBalance indicates an expected call of GetScriptHashBalance.
+func (mr *MockInterfaceMockRecorder) GetScriptHashBalance(ctx, scriptHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScriptHashBalance", reflect.TypeOf((*MockInterface)(nil).GetScriptHashBalance), ctx, scriptHash)
+}
+
+// GetSyncState mocks base method.
+func (m *MockInterface) GetSyncState(ctx context.Context) (*mongo.SyncState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSyncState", ctx)
+	ret0, _ := ret[0].(*mongo.SyncState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSyncState indicates an expected call of GetSyncState.
+func (mr *MockInterfaceMockRecorder) GetSyncState(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSyncState", reflect.TypeOf((*MockInterface)(nil).GetSyncState), ctx)
+}
+
+// GetUndoEntry mocks base method.
+func (m *MockInterface) GetUndoEntry(ctx context.Context, height int, hash string) (*mongo.UndoEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUndoEntry", ctx, height, hash)
+	ret0, _ := ret[0].(*mongo.UndoEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUndoEntry indicates an expected call of GetUndoEntry.
+func (mr *MockInterfaceMockRecorder) GetUndoEntry(ctx, height, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUndoEntry", reflect.TypeOf((*MockInterface)(nil).GetUndoEntry), ctx, height, hash)
+}
+
 // InsertMany mocks base method.
 func (m *MockInterface) InsertMany(ctx context.Context, utxos []*mongo.UTXO) error {
 	m.ctrl.T.Helper()
@@ -92,3 +211,74 @@ func (mr *MockInterfaceMockRecorder) ListCoinsForAddress(ctx, address interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoinsForAddress", reflect.TypeOf((*MockInterface)(nil).ListCoinsForAddress), ctx, address)
 }
+
+// ListUnspentByScriptHash mocks base method.
+func (m *MockInterface) ListUnspentByScriptHash(ctx context.Context, scriptHash string) ([]*mongo.UTXO, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnspentByScriptHash", ctx, scriptHash)
+	ret0, _ := ret[0].([]*mongo.UTXO)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnspentByScriptHash indicates an expected call of ListUnspentByScriptHash.
+func (mr *MockInterfaceMockRecorder) ListUnspentByScriptHash(ctx, scriptHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnspentByScriptHash", reflect.TypeOf((*MockInterface)(nil).ListUnspentByScriptHash), ctx, scriptHash)
+}
+
+// RemoveMempoolTransactions mocks base method.
+func (m *MockInterface) RemoveMempoolTransactions(ctx context.Context, txids []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMempoolTransactions", ctx, txids)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMempoolTransactions indicates an expected call of RemoveMempoolTransactions.
+func (mr *MockInterfaceMockRecorder) RemoveMempoolTransactions(ctx, txids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMempoolTransactions", reflect.TypeOf((*MockInterface)(nil).RemoveMempoolTransactions), ctx, txids)
+}
+
+// RevertBlock mocks base method.
+func (m *MockInterface) RevertBlock(ctx context.Context, height int, restoreUtxos []*mongo.UTXO, state *mongo.SyncState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevertBlock", ctx, height, restoreUtxos, state)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevertBlock indicates an expected call of RevertBlock.
+func (mr *MockInterfaceMockRecorder) RevertBlock(ctx, height, restoreUtxos, state interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertBlock", reflect.TypeOf((*MockInterface)(nil).RevertBlock), ctx, height, restoreUtxos, state)
+}
+
+// ScanUtxos mocks base method.
+func (m *MockInterface) ScanUtxos(ctx context.Context, batchSize int, fn func([]*mongo.UTXO) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanUtxos", ctx, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScanUtxos indicates an expected call of ScanUtxos.
+func (mr *MockInterfaceMockRecorder) ScanUtxos(ctx, batchSize, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanUtxos", reflect.TypeOf((*MockInterface)(nil).ScanUtxos), ctx, batchSize, fn)
+}
+
+// UpdateAmounts mocks base method.
+func (m *MockInterface) UpdateAmounts(ctx context.Context, utxos []*mongo.UTXO) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAmounts", ctx, utxos)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAmounts indicates an expected call of UpdateAmounts.
+func (mr *MockInterfaceMockRecorder) UpdateAmounts(ctx, utxos interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAmounts", reflect.TypeOf((*MockInterface)(nil).UpdateAmounts), ctx, utxos)
+}
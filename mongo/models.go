@@ -1,16 +1,50 @@
 package mongo
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
 type ScriptType string
 
 const (
-	ScriptType_P2PK        ScriptType = "p2pk"
-	ScriptType_P2PKH       ScriptType = "p2pkh"
-	ScriptType_P2SH        ScriptType = "p2sh"
-	ScriptType_P2WKH       ScriptType = "p2wkh"
-	ScriptType_P2WSH       ScriptType = "p2wsh"
-	ScriptType_NonStandard ScriptType = "non-standard"
+	ScriptType_P2PK           ScriptType = "p2pk"
+	ScriptType_P2PKH          ScriptType = "p2pkh"
+	ScriptType_P2SH           ScriptType = "p2sh"
+	ScriptType_P2WKH          ScriptType = "p2wkh"
+	ScriptType_P2WSH          ScriptType = "p2wsh"
+	ScriptType_P2TR           ScriptType = "p2tr"
+	ScriptType_WitnessUnknown ScriptType = "witness-unknown"
+	ScriptType_Anchor         ScriptType = "p2a"
+	ScriptType_Multisig       ScriptType = "multisig"
+	ScriptType_NullData       ScriptType = "null-data"
+	ScriptType_NonStandard    ScriptType = "non-standard"
 )
 
+var ScriptTypes = []ScriptType{
+	ScriptType_P2PK,
+	ScriptType_P2PKH,
+	ScriptType_P2SH,
+	ScriptType_P2WKH,
+	ScriptType_P2WSH,
+	ScriptType_P2TR,
+	ScriptType_WitnessUnknown,
+	ScriptType_Anchor,
+	ScriptType_Multisig,
+	ScriptType_NullData,
+	ScriptType_NonStandard,
+}
+
+func (t ScriptType) IsValid() bool {
+	for _, scriptType := range ScriptTypes {
+		if t == scriptType {
+			return true
+		}
+	}
+	return false
+}
+
 type UTXO struct {
 	TxID     string     `json:"tx_id" bson:"tx_id"`
 	Vout     int        `json:"vout" bson:"vout"`
@@ -21,4 +55,116 @@ type UTXO struct {
 	Script   string     `json:"script" bson:"script"`
 	Type     ScriptType `json:"type" bson:"type"` // TODO: maybe not string?
 	Address  string     `json:"address" bson:"address"`
+	// Addresses holds the P2PKH address of every key of a bare multisig output, which has no address of its own
+	Addresses []string `json:"addresses,omitempty" bson:"addresses,omitempty"`
+	// ScriptHash is the Electrum-style scripthash of Script, set on every output whether it has an address or not
+	ScriptHash string `json:"scripthash,omitempty" bson:"scripthash,omitempty"`
+	// Unconfirmed is only set on outputs of mempool transactions
+	Unconfirmed bool `json:"unconfirmed,omitempty" bson:"unconfirmed,omitempty"`
+}
+
+// BlockHeader records a block that has been applied to the UTXO collection
+type BlockHeader struct {
+	Height       int    `json:"height" bson:"height"`
+	Hash         string `json:"hash" bson:"hash"`
+	PreviousHash string `json:"previous_hash" bson:"previous_hash"`
+}
+
+// SyncState is the checkpoint of the synchronizer, it's written together with the changes of every block
+type SyncState struct {
+	Height    int       `json:"height" bson:"height"`
+	Hash      string    `json:"hash" bson:"hash"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	Version   string    `json:"version" bson:"version"`
+}
+
+// BlockChanges holds every write needed to apply one block
+type BlockChanges struct {
+	Header      *BlockHeader
+	DeleteKeys  []bson.M
+	InsertUtxos []*UTXO
+	OpReturns   []*OpReturn
+	// SpentInputs are the actual sizes of the inputs of the block, which the spend size history is built from
+	SpentInputs []*SpentInput
+	// UsedAddresses are the addresses the block pays to, only with the address history index
+	UsedAddresses []string
+}
+
+// UsedAddress is an address which received an output, first at Height
+type UsedAddress struct {
+	Address string `json:"address" bson:"address"`
+	Height  int    `json:"height" bson:"height"`
+}
+
+// SpentInput is the vsize of the input which spent an output
+type SpentInput struct {
+	TxID string
+	Vout int
+	Size int64
+}
+
+// SpendSize is the largest input vsize, with signatures of the maximum size, seen spending an output of a p2sh or p2wsh
+// script when it differed from the estimate of its type, outputs of the script then get that size
+type SpendSize struct {
+	ScriptHash string `json:"scripthash" bson:"scripthash"`
+	Address    string `json:"address" bson:"address"`
+	Size       int64  `json:"size" bson:"size"`
+}
+
+// UndoEntry journals the UTXOs spent by one block so that the block can be reverted
+type UndoEntry struct {
+	Height int     `json:"height" bson:"height"`
+	Hash   string  `json:"hash" bson:"hash"`
+	Spent  []*UTXO `json:"spent" bson:"spent"`
+}
+
+// OpReturn is a provably unspendable OP_RETURN output, kept apart from the UTXO set
+type OpReturn struct {
+	TxID    string `json:"tx_id" bson:"tx_id"`
+	Vout    int    `json:"vout" bson:"vout"`
+	Height  int    `json:"height" bson:"height"`
+	Payload string `json:"payload" bson:"payload"` // hex of the data pushed after OP_RETURN
+}
+
+// PendingSpend is an outpoint spent by a transaction which is still in the mempool
+type PendingSpend struct {
+	TxID       string   `json:"tx_id" bson:"tx_id"`
+	Vout       int      `json:"vout" bson:"vout"`
+	SpentBy    string   `json:"spent_by" bson:"spent_by"`
+	Address    string   `json:"address" bson:"address"` // address of the spent output, empty until it's known
+	Addresses  []string `json:"addresses,omitempty" bson:"addresses,omitempty"`
+	ScriptHash string   `json:"scripthash" bson:"scripthash"` // empty until the spent output is known
+}
+
+// MempoolTransaction holds the outputs created and spent by one unconfirmed transaction
+type MempoolTransaction struct {
+	TxID    string
+	Outputs []*UTXO
+	Spends  []*PendingSpend
+}
+
+// UnspentOutput is an entry of Electrum's blockchain.scripthash.listunspent
+type UnspentOutput struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  int    `json:"tx_pos"`
+	Height int    `json:"height"` // 0 for outputs of mempool transactions
+	Value  int64  `json:"value"`
+}
+
+// Balance is the sum of the unspent outputs of an address or a scripthash, in satoshis
+type Balance struct {
+	Confirmed int64 `json:"confirmed" bson:"confirmed"`
+	// Unconfirmed is the net change pending in the mempool, negative when more is spent than received
+	Unconfirmed int64 `json:"unconfirmed" bson:"unconfirmed"`
+}
+
+// AddressBalance details the balance of an address, the unconfirmed amounts are only tracked with the mempool
+type AddressBalance struct {
+	Balance `bson:",inline"`
+	// Immature is the part of Confirmed in coinbase outputs which can't be spent yet
+	Immature  int64 `json:"immature" bson:"immature"`
+	UTXOCount int64 `json:"utxo_count" bson:"utxo_count"`
+	// UnconfirmedReceived and UnconfirmedSent are what mempool transactions pay to and spend from the address
+	UnconfirmedReceived int64 `json:"unconfirmed_received" bson:"unconfirmed_received"`
+	UnconfirmedSent     int64 `json:"unconfirmed_sent" bson:"unconfirmed_sent"`
 }
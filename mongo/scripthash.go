@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"context"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ScriptHashPattern matches the hex scripthashes stored on the outputs
+var ScriptHashPattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+// ElectrumHeight is the height Electrum reports for the output, 0 while it's unconfirmed
+func (utxo *UTXO) ElectrumHeight() int {
+	if utxo.Unconfirmed {
+		return 0
+	}
+	return utxo.Height
+}
+
+// NewUnspentOutputs lists `utxos` the way blockchain.scripthash.listunspent does
+func NewUnspentOutputs(utxos []*UTXO) []*UnspentOutput {
+	unspent := make([]*UnspentOutput, 0, len(utxos))
+	for _, utxo := range utxos {
+		unspent = append(unspent, &UnspentOutput{
+			TxHash: utxo.TxID,
+			TxPos:  utxo.Vout,
+			Height: utxo.ElectrumHeight(),
+			Value:  utxo.Amount,
+		})
+	}
+	return unspent
+}
+
+// ListUnspentByScriptHash returns the outputs paying to `scriptHash` which no mempool transaction spends,
+// the confirmed ones by height followed by the unconfirmed ones
+func (s server) ListUnspentByScriptHash(ctx context.Context, scriptHash string) ([]*UTXO, error) {
+	filter := bson.M{KEY_SCRIPTHASH: scriptHash}
+
+	var spends []*PendingSpend
+	cur, err := s.spendsCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &spends); err != nil {
+		return nil, err
+	}
+	if len(spends) > 0 {
+		var spentKeys []bson.M
+		for _, spend := range spends {
+			spentKeys = append(spentKeys, bson.M{KEY_TXID: spend.TxID, KEY_VOUT: spend.Vout})
+		}
+		filter[KEY_NOR] = spentKeys
+	}
+
+	var utxos []*UTXO
+	// a stable order, Electrum statuses are derived from it
+	order := bson.D{{Key: KEY_HEIGHT, Value: 1}, {Key: KEY_TXID, Value: 1}, {Key: KEY_VOUT, Value: 1}}
+	cur, err = s.collection.Find(ctx, filter, options.Find().SetSort(order))
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &utxos); err != nil {
+		return nil, err
+	}
+
+	var unconfirmed []*UTXO
+	cur, err = s.mempoolCollection.Find(ctx, filter, options.Find().SetSort(order))
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &unconfirmed); err != nil {
+		return nil, err
+	}
+	return append(utxos, unconfirmed...), nil
+}
@@ -2,9 +2,12 @@ package mongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo/options"
 
@@ -18,22 +21,190 @@ const (
 	KEY_TXID    = "tx_id"
 	KEY_VOUT    = "vout"
 	KEY_AMOUNT  = "amount"
+	KEY_TYPE    = "type"
 	KEY_GT      = "$gt"
+	KEY_GTE     = "$gte"
+	KEY_SET     = "$set"
+	KEY_ID      = "_id"
+	KEY_HASH    = "hash"
+	KEY_OR      = "$or"
+	KEY_LTE     = "$lte"
+	KEY_IN      = "$in"
+	KEY_NOR     = "$nor"
+
+	KEY_ADDRESSES  = "addresses"
+	KEY_SCRIPTHASH = "scripthash"
+	KEY_SPENT_BY   = "spent_by"
+	KEY_PAYLOAD    = "payload"
+	KEY_COINBASE   = "coinbase"
+	KEY_SIZE       = "size"
+	KEY_MAX        = "$max"
+	KEY_MIN        = "$min"
+
+	KEY_SET_ON_INSERT = "$setOnInsert"
 
 	ENV_MONGO_UTXO_KEY_INDEX_NAME = "MONGO_UTXO_KEY_INDEX_NAME"
+	// ENV_UNDO_RETENTION_DEPTH is how many of the latest blocks keep their undo entry, 0 disables the journal
+	ENV_UNDO_RETENTION_DEPTH     = "UNDO_RETENTION_DEPTH"
+	DEFAULT_UNDO_RETENTION_DEPTH = 288
+
+	// BLOCK_COLLECTION_SUFFIX is appended to the utxo collection name to name the collection of applied blocks
+	BLOCK_COLLECTION_SUFFIX = "-blocks"
+	// SYNC_STATE_COLLECTION_SUFFIX is appended to the utxo collection name to name the collection holding the checkpoint
+	SYNC_STATE_COLLECTION_SUFFIX = "-sync-state"
+	SYNC_STATE_ID                = "checkpoint"
+	// UNDO_COLLECTION_SUFFIX is appended to the utxo collection name to name the undo journal
+	UNDO_COLLECTION_SUFFIX = "-undo"
+	// MEMPOOL_COLLECTION_SUFFIX is appended to the utxo collection name to name the collection of unconfirmed outputs
+	MEMPOOL_COLLECTION_SUFFIX = "-mempool"
+	// MEMPOOL_SPENDS_COLLECTION_SUFFIX is appended to the utxo collection name to name the collection of pending spends
+	MEMPOOL_SPENDS_COLLECTION_SUFFIX = "-mempool-spends"
+	// OP_RETURN_COLLECTION_SUFFIX is appended to the utxo collection name to name the collection of OP_RETURN outputs
+	OP_RETURN_COLLECTION_SUFFIX = "-op-return"
+	// SPEND_SIZE_COLLECTION_SUFFIX is appended to the utxo collection name to name the spend size history
+	SPEND_SIZE_COLLECTION_SUFFIX = "-spend-sizes"
+	// ADDRESS_COLLECTION_SUFFIX is appended to the utxo collection name to name the address history index
+	ADDRESS_COLLECTION_SUFFIX = "-addresses"
+
+	// APPLY_MAX_ATTEMPTS is how many times a block is written when transactions are unavailable
+	APPLY_MAX_ATTEMPTS = 3
+	APPLY_RETRY_DELAY  = 2 * time.Second
 )
 
+var ErrNotFound = errors.New("document not found")
+
 type server struct {
-	collection *mongo.Collection
+	client              *mongo.Client
+	collection          *mongo.Collection
+	blockCollection     *mongo.Collection
+	syncStateCollection *mongo.Collection
+	undoCollection      *mongo.Collection
+	mempoolCollection   *mongo.Collection
+	spendsCollection    *mongo.Collection
+	opReturnCollection  *mongo.Collection
+	spendSizeCollection *mongo.Collection
+	addressCollection   *mongo.Collection
+	transactions        bool
+	undoRetentionDepth  int
 }
 
 func New(c *mongo.Client, db string, collection string) Interface {
-	return &server{
-		collection: c.Database(db).Collection(collection),
+	transactions := supportsTransactions(c)
+	log.Println("[debug] mongo multi-document transactions supported: ", transactions)
+
+	s := &server{
+		client:              c,
+		collection:          c.Database(db).Collection(collection),
+		blockCollection:     c.Database(db).Collection(collection + BLOCK_COLLECTION_SUFFIX),
+		syncStateCollection: c.Database(db).Collection(collection + SYNC_STATE_COLLECTION_SUFFIX),
+		undoCollection:      c.Database(db).Collection(collection + UNDO_COLLECTION_SUFFIX),
+		mempoolCollection:   c.Database(db).Collection(collection + MEMPOOL_COLLECTION_SUFFIX),
+		spendsCollection:    c.Database(db).Collection(collection + MEMPOOL_SPENDS_COLLECTION_SUFFIX),
+		opReturnCollection:  c.Database(db).Collection(collection + OP_RETURN_COLLECTION_SUFFIX),
+		spendSizeCollection: c.Database(db).Collection(collection + SPEND_SIZE_COLLECTION_SUFFIX),
+		addressCollection:   c.Database(db).Collection(collection + ADDRESS_COLLECTION_SUFFIX),
+		transactions:        transactions,
+		undoRetentionDepth:  undoRetentionDepth(),
+	}
+	s.ensureIndexes()
+	return s
+}
+
+func undoRetentionDepth() int {
+	value := os.Getenv(ENV_UNDO_RETENTION_DEPTH)
+	if value == "" {
+		return DEFAULT_UNDO_RETENTION_DEPTH
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 {
+		log.Printf("[warning] invalid %s %q, using %d\n", ENV_UNDO_RETENTION_DEPTH, value, DEFAULT_UNDO_RETENTION_DEPTH)
+		return DEFAULT_UNDO_RETENTION_DEPTH
 	}
+	return depth
+}
+
+// ensureIndexes creates the indexes of the collections owned by the synchronizer and the ones on the
+// fields it derives, the utxo collection's other indexes are managed by operators (see ENV_MONGO_UTXO_KEY_INDEX_NAME)
+func (s server) ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// sparse, as only bare multisig outputs have addresses and outputs synced by earlier versions lack a scripthash
+	if _, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: KEY_ADDRESSES, Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: KEY_SCRIPTHASH, Value: 1}}, Options: options.Index().SetSparse(true)},
+	}); err != nil {
+		log.Println("[error] failed to create utxo address indexes with error: ", err.Error())
+	}
+
+	if _, err := s.blockCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: KEY_HEIGHT, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		log.Println("[error] failed to create block height index with error: ", err.Error())
+	}
+	if _, err := s.undoCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: KEY_HEIGHT, Value: 1}, {Key: KEY_HASH, Value: 1}},
+	}); err != nil {
+		log.Println("[error] failed to create undo height index with error: ", err.Error())
+	}
+	for _, collection := range []*mongo.Collection{s.mempoolCollection, s.spendsCollection} {
+		if _, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: KEY_TXID, Value: 1}, {Key: KEY_VOUT, Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: KEY_ADDRESS, Value: 1}}},
+			{Keys: bson.D{{Key: KEY_ADDRESSES, Value: 1}}, Options: options.Index().SetSparse(true)},
+			{Keys: bson.D{{Key: KEY_SCRIPTHASH, Value: 1}}},
+		}); err != nil {
+			log.Println("[error] failed to create mempool indexes with error: ", err.Error())
+		}
+	}
+	if _, err := s.spendsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: KEY_SPENT_BY, Value: 1}},
+	}); err != nil {
+		log.Println("[error] failed to create pending spends index with error: ", err.Error())
+	}
+	if _, err := s.opReturnCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: KEY_TXID, Value: 1}, {Key: KEY_VOUT, Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: KEY_PAYLOAD, Value: 1}, {Key: KEY_HEIGHT, Value: 1}}},
+		{Keys: bson.D{{Key: KEY_HEIGHT, Value: 1}}},
+	}); err != nil {
+		log.Println("[error] failed to create op_return indexes with error: ", err.Error())
+	}
+	if _, err := s.spendSizeCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: KEY_SCRIPTHASH, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		log.Println("[error] failed to create spend size index with error: ", err.Error())
+	}
+	if _, err := s.addressCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: KEY_ADDRESS, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		log.Println("[error] failed to create address history index with error: ", err.Error())
+	}
+}
+
+// supportsTransactions tells whether the deployment is a replica set or a sharded cluster,
+// standalone servers reject multi-document transactions
+func supportsTransactions(c *mongo.Client) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result := bson.M{}
+	if err := c.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&result); err != nil {
+		log.Println("[error] failed to get mongo deployment topology with error: ", err.Error())
+		return false
+	}
+	if _, ok := result["setName"]; ok {
+		return true
+	}
+	return result["msg"] == "isdbgrid"
 }
 
 func (s server) InsertMany(ctx context.Context, utxos []*UTXO) error {
+	if len(utxos) == 0 {
+		return nil
+	}
 	var documents []interface{}
 	for _, utxo := range utxos {
 		documents = append(documents, utxo)
@@ -66,17 +237,24 @@ func (s server) ListCoinsForAddress(ctx context.Context, address string) ([]*UTX
 	return utxos, nil
 }
 
-func (s server) GetMaxHeight(ctx context.Context) int {
+// GetMaxHeight returns the height of the highest UTXO, ErrNotFound when the collection is empty
+func (s server) GetMaxHeight(ctx context.Context) (int, error) {
 	cur := s.collection.FindOne(ctx, bson.M{}, &options.FindOneOptions{Sort: bson.M{KEY_HEIGHT: -1}})
 	utxo := &UTXO{}
-	if err := cur.Decode(&utxo); err != nil {
-		log.Println("[error] failed to decode utxo with error: ", err.Error())
-		return -1
+	err := cur.Decode(&utxo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return -1, ErrNotFound
 	}
-	return utxo.Height
+	if err != nil {
+		return -1, err
+	}
+	return utxo.Height, nil
 }
 
 func (s server) DeleteMany(ctx context.Context, uniqueKeys []bson.M) error {
+	if len(uniqueKeys) == 0 {
+		return nil
+	}
 	var writeModels []mongo.WriteModel
 	for _, key := range uniqueKeys {
 		deleteModel := &mongo.DeleteManyModel{
@@ -91,3 +269,299 @@ func (s server) DeleteMany(ctx context.Context, uniqueKeys []bson.M) error {
 	_, err := s.collection.BulkWrite(ctx, writeModels)
 	return err
 }
+
+// ScanUtxos walks the whole utxo collection, in no particular order, handing `fn` up to `batchSize` UTXOs at a time
+func (s server) ScanUtxos(ctx context.Context, batchSize int, fn func(utxos []*UTXO) error) error {
+	cur, err := s.collection.Find(ctx, bson.M{}, options.Find().SetBatchSize(int32(batchSize)))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	batch := make([]*UTXO, 0, batchSize)
+	for cur.Next(ctx) {
+		utxo := &UTXO{}
+		if err := cur.Decode(utxo); err != nil {
+			return err
+		}
+		if batch = append(batch, utxo); len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]*UTXO, 0, batchSize)
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// UpdateAmounts overwrites the amount of the given UTXOs, keyed on txid and vout
+func (s server) UpdateAmounts(ctx context.Context, utxos []*UTXO) error {
+	if len(utxos) == 0 {
+		return nil
+	}
+	var writeModels []mongo.WriteModel
+	for _, utxo := range utxos {
+		updateModel := mongo.NewUpdateOneModel().
+			SetFilter(bson.M{KEY_TXID: utxo.TxID, KEY_VOUT: utxo.Vout}).
+			SetUpdate(bson.M{KEY_SET: bson.M{KEY_AMOUNT: utxo.Amount}})
+		if utxoKeyIndex := os.Getenv(ENV_MONGO_UTXO_KEY_INDEX_NAME); utxoKeyIndex != "" {
+			updateModel.SetHint(utxoKeyIndex)
+		}
+		writeModels = append(writeModels, updateModel)
+	}
+
+	_, err := s.collection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s server) GetBlockHeader(ctx context.Context, height int) (*BlockHeader, error) {
+	header := &BlockHeader{}
+	err := s.blockCollection.FindOne(ctx, bson.M{KEY_HEIGHT: height}).Decode(header)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (s server) GetSyncState(ctx context.Context) (*SyncState, error) {
+	state := &SyncState{}
+	err := s.syncStateCollection.FindOne(ctx, bson.M{KEY_ID: SYNC_STATE_ID}).Decode(state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s server) GetUndoEntry(ctx context.Context, height int, hash string) (*UndoEntry, error) {
+	entry := &UndoEntry{}
+	err := s.undoCollection.FindOne(ctx, bson.M{KEY_HEIGHT: height, KEY_HASH: hash}).Decode(entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ApplyBlock writes the changes of one block together with the checkpoint. On a replica set everything
+// commits in one transaction, otherwise the idempotent writes are retried and the checkpoint goes last,
+// so an interrupted block is simply applied again
+func (s server) ApplyBlock(ctx context.Context, changes *BlockChanges, state *SyncState) error {
+	return s.atomically(ctx, func(ctx context.Context) error {
+		spent, err := s.findSpent(ctx, changes.DeleteKeys)
+		if err != nil {
+			return fmt.Errorf("failed to find spent UTXOs: %w", err)
+		}
+		if err := s.journalSpent(ctx, changes, spent); err != nil {
+			return fmt.Errorf("failed to journal spent UTXOs: %w", err)
+		}
+		if err := s.DeleteMany(ctx, changes.DeleteKeys); err != nil {
+			return fmt.Errorf("failed to delete spent UTXOs: %w", err)
+		}
+		if err := s.recordSpendSizes(ctx, spent, changes.SpentInputs); err != nil {
+			return fmt.Errorf("failed to record spend sizes: %w", err)
+		}
+		if err := s.fillSizes(ctx, changes.InsertUtxos); err != nil {
+			return fmt.Errorf("failed to look up spend sizes: %w", err)
+		}
+		if err := s.upsertMany(ctx, changes.InsertUtxos); err != nil {
+			return fmt.Errorf("failed to insert new UTXOs: %w", err)
+		}
+		if err := s.saveOpReturns(ctx, changes.OpReturns); err != nil {
+			return fmt.Errorf("failed to save OP_RETURN outputs: %w", err)
+		}
+		if err := s.saveUsedAddresses(ctx, changes.Header.Height, changes.UsedAddresses); err != nil {
+			return fmt.Errorf("failed to save used addresses: %w", err)
+		}
+		if err := s.saveBlockHeader(ctx, changes.Header); err != nil {
+			return fmt.Errorf("failed to save block header: %w", err)
+		}
+		if err := s.pruneUndo(ctx, state.Height); err != nil {
+			return fmt.Errorf("failed to prune undo journal: %w", err)
+		}
+		return s.saveSyncState(ctx, state)
+	})
+}
+
+// RevertBlock removes the UTXOs created at `height`, restores the ones spent there and
+// moves the checkpoint back to `state`
+func (s server) RevertBlock(ctx context.Context, height int, restoreUtxos []*UTXO, state *SyncState) error {
+	return s.atomically(ctx, func(ctx context.Context) error {
+		if _, err := s.collection.DeleteMany(ctx, bson.M{KEY_HEIGHT: height}); err != nil {
+			return fmt.Errorf("failed to delete UTXOs created at height %d: %w", height, err)
+		}
+		if err := s.upsertMany(ctx, restoreUtxos); err != nil {
+			return fmt.Errorf("failed to restore spent UTXOs: %w", err)
+		}
+		if _, err := s.opReturnCollection.DeleteMany(ctx, bson.M{KEY_HEIGHT: height}); err != nil {
+			return fmt.Errorf("failed to delete OP_RETURN outputs created at height %d: %w", height, err)
+		}
+		if _, err := s.blockCollection.DeleteMany(ctx, bson.M{KEY_HEIGHT: bson.M{KEY_GTE: height}}); err != nil {
+			return fmt.Errorf("failed to delete block header: %w", err)
+		}
+		if _, err := s.undoCollection.DeleteMany(ctx, bson.M{KEY_HEIGHT: bson.M{KEY_GTE: height}}); err != nil {
+			return fmt.Errorf("failed to delete undo entry: %w", err)
+		}
+		return s.saveSyncState(ctx, state)
+	})
+}
+
+// atomically runs `fn` in a transaction when the deployment supports it and retries it otherwise,
+// `fn` must only do idempotent writes
+func (s server) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.transactions {
+		session, err := s.client.StartSession()
+		if err != nil {
+			return fmt.Errorf("failed to start session: %w", err)
+		}
+		defer session.EndSession(ctx)
+
+		_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sessCtx)
+		})
+		return err
+	}
+
+	var err error
+	for attempt := 1; attempt <= APPLY_MAX_ATTEMPTS; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		log.Printf("[warning] write attempt %d/%d failed with error: %s\n", attempt, APPLY_MAX_ATTEMPTS, err.Error())
+		if attempt < APPLY_MAX_ATTEMPTS {
+			time.Sleep(APPLY_RETRY_DELAY)
+		}
+	}
+	return err
+}
+
+// upsertMany writes utxos keyed on txid and vout so that writing them twice is harmless
+func (s server) upsertMany(ctx context.Context, utxos []*UTXO) error {
+	if len(utxos) == 0 {
+		return nil
+	}
+	var writeModels []mongo.WriteModel
+	for _, utxo := range utxos {
+		replaceModel := mongo.NewReplaceOneModel().
+			SetFilter(bson.M{KEY_TXID: utxo.TxID, KEY_VOUT: utxo.Vout}).
+			SetReplacement(utxo).
+			SetUpsert(true)
+		if utxoKeyIndex := os.Getenv(ENV_MONGO_UTXO_KEY_INDEX_NAME); utxoKeyIndex != "" {
+			replaceModel.SetHint(utxoKeyIndex)
+		}
+		writeModels = append(writeModels, replaceModel)
+	}
+
+	_, err := s.collection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// saveUsedAddresses records the addresses paid to at `height`. Reverted blocks leave their addresses recorded, at
+// worst a wallet scan goes a little further
+func (s server) saveUsedAddresses(ctx context.Context, height int, addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	var writeModels []mongo.WriteModel
+	for _, address := range addresses {
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{KEY_ADDRESS: address}).
+			SetUpdate(bson.M{KEY_MIN: bson.M{KEY_HEIGHT: height}}).
+			SetUpsert(true))
+	}
+	_, err := s.addressCollection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s server) saveOpReturns(ctx context.Context, opReturns []*OpReturn) error {
+	if len(opReturns) == 0 {
+		return nil
+	}
+	var writeModels []mongo.WriteModel
+	for _, opReturn := range opReturns {
+		writeModels = append(writeModels, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{KEY_TXID: opReturn.TxID, KEY_VOUT: opReturn.Vout}).
+			SetReplacement(opReturn).
+			SetUpsert(true))
+	}
+
+	_, err := s.opReturnCollection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// journalSpent records the UTXOs the block is about to delete. An existing entry is kept as it is,
+// since the UTXOs may already be gone when a block is written again
+func (s server) journalSpent(ctx context.Context, changes *BlockChanges, spent []*UTXO) error {
+	if s.undoRetentionDepth == 0 {
+		return nil
+	}
+
+	entry := &UndoEntry{
+		Height: changes.Header.Height,
+		Hash:   changes.Header.Hash,
+		Spent:  spent,
+	}
+	if entry.Spent == nil {
+		entry.Spent = []*UTXO{}
+	}
+
+	_, err := s.undoCollection.UpdateOne(ctx,
+		bson.M{KEY_HEIGHT: entry.Height, KEY_HASH: entry.Hash},
+		bson.M{KEY_SET_ON_INSERT: entry},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// findSpent returns the UTXOs a block spends, only the ones revealing their spend size when the journal is disabled
+func (s server) findSpent(ctx context.Context, deleteKeys []bson.M) ([]*UTXO, error) {
+	if len(deleteKeys) == 0 {
+		return nil, nil
+	}
+	filter := bson.M{KEY_OR: deleteKeys}
+	if s.undoRetentionDepth == 0 {
+		filter[KEY_TYPE] = bson.M{KEY_IN: sizeRevealingTypes}
+	}
+
+	cur, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var spent []*UTXO
+	if err := cur.All(ctx, &spent); err != nil {
+		return nil, err
+	}
+	return spent, nil
+}
+
+// pruneUndo drops the undo entries which fell out of the retention depth
+func (s server) pruneUndo(ctx context.Context, height int) error {
+	if s.undoRetentionDepth == 0 {
+		return nil
+	}
+	_, err := s.undoCollection.DeleteMany(ctx, bson.M{KEY_HEIGHT: bson.M{KEY_LTE: height - s.undoRetentionDepth}})
+	return err
+}
+
+func (s server) saveBlockHeader(ctx context.Context, header *BlockHeader) error {
+	_, err := s.blockCollection.ReplaceOne(ctx, bson.M{KEY_HEIGHT: header.Height}, header, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s server) saveSyncState(ctx context.Context, state *SyncState) error {
+	_, err := s.syncStateCollection.UpdateOne(ctx, bson.M{KEY_ID: SYNC_STATE_ID}, bson.M{KEY_SET: state}, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SPEND_SIZE_QUERY_CHUNK bounds the number of scripthashes looked up in the spend size history at once
+const SPEND_SIZE_QUERY_CHUNK = 1000
+
+// recordSpendSizes adds the sizes revealed by the spends of p2sh and p2wsh outputs to the history and resizes the
+// unspent outputs of the same scripts. The history isn't reverted on reorgs, a revealed script stays valid
+func (s server) recordSpendSizes(ctx context.Context, spent []*UTXO, inputs []*SpentInput) error {
+	if len(spent) == 0 || len(inputs) == 0 {
+		return nil
+	}
+	sizes := make(map[string]int64, len(inputs))
+	for _, input := range inputs {
+		sizes[fmt.Sprintf("%s:%d", input.TxID, input.Vout)] = input.Size
+	}
+
+	revealed := map[string]*SpendSize{}
+	for _, utxo := range spent {
+		if !utxo.Type.RevealsInputSize() || utxo.ScriptHash == "" {
+			continue
+		}
+		size, ok := sizes[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)]
+		if !ok || size == utxo.Type.EstimatedInputSize() {
+			continue
+		}
+		if known, ok := revealed[utxo.ScriptHash]; !ok || known.Size < size {
+			revealed[utxo.ScriptHash] = &SpendSize{ScriptHash: utxo.ScriptHash, Address: utxo.Address, Size: size}
+		}
+	}
+	if len(revealed) == 0 {
+		return nil
+	}
+
+	var scriptHashes []string
+	var historyModels []mongo.WriteModel
+	for scriptHash, spendSize := range revealed {
+		scriptHashes = append(scriptHashes, scriptHash)
+		historyModels = append(historyModels, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{KEY_SCRIPTHASH: scriptHash}).
+			SetUpdate(bson.M{KEY_MAX: bson.M{KEY_SIZE: spendSize.Size}, KEY_SET: bson.M{KEY_ADDRESS: spendSize.Address}}).
+			SetUpsert(true))
+	}
+	if _, err := s.spendSizeCollection.BulkWrite(ctx, historyModels, options.BulkWrite().SetOrdered(false)); err != nil {
+		return err
+	}
+
+	// earlier spends may have revealed a larger size
+	history, err := s.spendSizes(ctx, scriptHashes)
+	if err != nil {
+		return err
+	}
+	var utxoModels []mongo.WriteModel
+	for scriptHash, size := range history {
+		utxoModels = append(utxoModels, mongo.NewUpdateManyModel().
+			SetFilter(bson.M{KEY_SCRIPTHASH: scriptHash}).
+			SetUpdate(bson.M{KEY_SET: bson.M{KEY_SIZE: size}}))
+	}
+	_, err = s.collection.BulkWrite(ctx, utxoModels, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// fillSizes gives the p2sh and p2wsh outputs among `utxos` the size earlier spends of their script revealed
+func (s server) fillSizes(ctx context.Context, utxos []*UTXO) error {
+	var scriptHashes []string
+	for _, utxo := range utxos {
+		if utxo.Type.RevealsInputSize() && utxo.ScriptHash != "" {
+			scriptHashes = append(scriptHashes, utxo.ScriptHash)
+		}
+	}
+	if len(scriptHashes) == 0 {
+		return nil
+	}
+
+	history, err := s.spendSizes(ctx, scriptHashes)
+	if err != nil {
+		return err
+	}
+	for _, utxo := range utxos {
+		if size, ok := history[utxo.ScriptHash]; ok {
+			utxo.Size = size
+		}
+	}
+	return nil
+}
+
+// spendSizes returns the recorded sizes of the scripts of `scriptHashes` which have one
+func (s server) spendSizes(ctx context.Context, scriptHashes []string) (map[string]int64, error) {
+	history := map[string]int64{}
+	for start := 0; start < len(scriptHashes); start += SPEND_SIZE_QUERY_CHUNK {
+		end := start + SPEND_SIZE_QUERY_CHUNK
+		if end > len(scriptHashes) {
+			end = len(scriptHashes)
+		}
+
+		cur, err := s.spendSizeCollection.Find(ctx, bson.M{KEY_SCRIPTHASH: bson.M{KEY_IN: scriptHashes[start:end]}})
+		if err != nil {
+			return nil, err
+		}
+		var spendSizes []*SpendSize
+		if err := cur.All(ctx, &spendSizes); err != nil {
+			return nil, err
+		}
+		for _, spendSize := range spendSizes {
+			history[spendSize.ScriptHash] = spendSize.Size
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,103 @@
+package mongo
+
+import (
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+)
+
+var MapMongoScriptType2BlockScriptType = map[ScriptType]fullnode.ScriptType{
+	ScriptType_P2PK:           fullnode.ScriptType_P2PK,
+	ScriptType_P2PKH:          fullnode.ScriptType_P2PKH,
+	ScriptType_P2SH:           fullnode.ScriptType_P2SH,
+	ScriptType_P2WKH:          fullnode.ScriptType_P2WKH,
+	ScriptType_P2WSH:          fullnode.ScriptType_P2WSH,
+	ScriptType_P2TR:           fullnode.ScriptType_P2TR,
+	ScriptType_WitnessUnknown: fullnode.ScriptType_WitnessUnknown,
+	ScriptType_Anchor:         fullnode.ScriptType_Anchor,
+	ScriptType_Multisig:       fullnode.ScriptType_Multisig,
+	ScriptType_NullData:       fullnode.ScriptType_NullData,
+	ScriptType_NonStandard:    fullnode.ScriptType_NonStandard,
+}
+
+var MapBlockScriptType2MongoScriptType = map[fullnode.ScriptType]ScriptType{
+	fullnode.ScriptType_P2PK:           ScriptType_P2PK,
+	fullnode.ScriptType_P2PKH:          ScriptType_P2PKH,
+	fullnode.ScriptType_P2SH:           ScriptType_P2SH,
+	fullnode.ScriptType_P2WKH:          ScriptType_P2WKH,
+	fullnode.ScriptType_P2WSH:          ScriptType_P2WSH,
+	fullnode.ScriptType_P2TR:           ScriptType_P2TR,
+	fullnode.ScriptType_WitnessUnknown: ScriptType_WitnessUnknown,
+	fullnode.ScriptType_Anchor:         ScriptType_Anchor,
+	fullnode.ScriptType_Multisig:       ScriptType_Multisig,
+	fullnode.ScriptType_NullData:       ScriptType_NullData,
+	fullnode.ScriptType_NonStandard:    ScriptType_NonStandard,
+}
+
+// ScriptTypeFromNode maps the type reported by bitcoind, types introduced by later versions
+// are stored as witness-unknown or non-standard until they are mapped here
+func ScriptTypeFromNode(scriptType fullnode.ScriptType) ScriptType {
+	if mapped, ok := MapBlockScriptType2MongoScriptType[scriptType]; ok {
+		return mapped
+	}
+	if strings.HasPrefix(string(scriptType), fullnode.SCRIPT_TYPE_WITNESS_PREFIX) {
+		return ScriptType_WitnessUnknown
+	}
+	return ScriptType_NonStandard
+}
+
+// NewUTXO converts output `txout` of transaction `txid`, created at `height`, into the stored UTXO.
+// Outputs paying to bare public keys are stored under the P2PKH address of every key
+func NewUTXO(network *script.Network, txid string, txout *fullnode.TxOut, height int, coinbase bool) *UTXO {
+	scriptBytes := OutputScript(txout)
+	utxo := &UTXO{
+		TxID:       txid,
+		Vout:       txout.Index,
+		Height:     height,
+		Coinbase:   coinbase,
+		Amount:     int64(txout.Value),
+		Script:     txout.Script.Hex,
+		Type:       ScriptTypeFromNode(txout.Script.Type),
+		Address:    txout.Script.Address,
+		ScriptHash: script.ScriptHash(scriptBytes),
+	}
+	utxo.Size = utxo.Type.EstimatedInputSize()
+	if utxo.Address != "" {
+		return utxo
+	}
+
+	keyAddresses := script.KeyAddresses(scriptBytes, network)
+	switch {
+	case len(keyAddresses) == 1:
+		utxo.Address = keyAddresses[0]
+	case len(keyAddresses) > 1:
+		utxo.Addresses = keyAddresses
+	}
+	return utxo
+}
+
+// IsSpendable tells whether `txout` belongs in the UTXO set, OP_RETURN and oversized scripts never do
+func IsSpendable(txout *fullnode.TxOut) bool {
+	return !script.IsUnspendable(OutputScript(txout))
+}
+
+// OutputScript decodes the script of `txout`, nil when it has none
+func OutputScript(txout *fullnode.TxOut) []byte {
+	if txout.Script == nil {
+		return nil
+	}
+	scriptBytes, err := hex.DecodeString(txout.Script.Hex)
+	if err != nil {
+		log.Println("[warning] failed to decode output script with error: ", err.Error())
+		return nil
+	}
+	return scriptBytes
+}
+
+// IsImmature tells whether `utxo` is a coinbase output which can't be spent yet in a block at `spendHeight`
+func (utxo *UTXO) IsImmature(spendHeight int) bool {
+	return utxo.Coinbase && spendHeight-utxo.Height < COINBASE_MATURITY
+}
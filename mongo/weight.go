@@ -0,0 +1,86 @@
+package mongo
+
+const (
+	WITNESS_SCALE_FACTOR = 4
+	// TX_OVERHEAD_WEIGHT covers the version, the locktime and the input and output counts of a small transaction
+	TX_OVERHEAD_WEIGHT = (4 + 4 + 1 + 1) * WITNESS_SCALE_FACTOR
+	// SEGWIT_MARKER_WEIGHT is added once a transaction spends a witness output
+	SEGWIT_MARKER_WEIGHT = 2
+)
+
+// inputWeights are the weights of inputs spending each script type with signatures of the maximum size, or none when
+// the spending script can't be known from the output: p2sh is assumed to wrap p2wkh, as most p2sh outputs do
+var inputWeights = map[ScriptType]int64{
+	ScriptType_P2PK:   (32 + 4 + 1 + 1 + 72 + 4) * WITNESS_SCALE_FACTOR,
+	ScriptType_P2PKH:  (32 + 4 + 1 + 1 + 72 + 1 + 33 + 4) * WITNESS_SCALE_FACTOR,
+	ScriptType_P2SH:   (32+4+1+23+4)*WITNESS_SCALE_FACTOR + 1 + 1 + 72 + 1 + 33,
+	ScriptType_P2WKH:  (32+4+1+4)*WITNESS_SCALE_FACTOR + 1 + 1 + 72 + 1 + 33,
+	ScriptType_P2TR:   (32+4+1+4)*WITNESS_SCALE_FACTOR + 1 + 1 + 64,
+	ScriptType_Anchor: (32+4+1+4)*WITNESS_SCALE_FACTOR + 1,
+}
+
+// bestEffortInputWeights cover the types whose spending script depends on more than the output: p2wsh is assumed to be
+// a 2-of-3 multisig, the most common witness script
+var bestEffortInputWeights = map[ScriptType]int64{
+	ScriptType_P2WSH: (32+4+1+4)*WITNESS_SCALE_FACTOR + 1 + 1 + 2*(1+72) + 1 + 105,
+}
+
+// sizeRevealingTypes are the types whose actual input size is only known once an output is spent
+var sizeRevealingTypes = []ScriptType{ScriptType_P2SH, ScriptType_P2WSH}
+
+// outputScriptSizes are the sizes of the scripts of the types a wallet pays to
+var outputScriptSizes = map[ScriptType]int64{
+	ScriptType_P2PKH: 25,
+	ScriptType_P2SH:  23,
+	ScriptType_P2WKH: 22,
+	ScriptType_P2WSH: 34,
+	ScriptType_P2TR:  34,
+}
+
+// InputWeight estimates the weight of an input spending an output of this type, false when it can't be estimated
+func (t ScriptType) InputWeight() (int64, bool) {
+	weight, ok := inputWeights[t]
+	return weight, ok
+}
+
+// EstimatedInputSize is the vsize of an input spending an output of this type, exact for single-key types and
+// best-effort for p2sh and p2wsh, 0 when it can't be estimated (bare multisig, non-standard, unknown witness versions)
+func (t ScriptType) EstimatedInputSize() int64 {
+	weight, ok := t.InputWeight()
+	if !ok {
+		if weight, ok = bestEffortInputWeights[t]; !ok {
+			return 0
+		}
+	}
+	return (weight + WITNESS_SCALE_FACTOR - 1) / WITNESS_SCALE_FACTOR
+}
+
+// RevealsInputSize tells whether spending an output of this type shows a size the type alone doesn't tell
+func (t ScriptType) RevealsInputSize() bool {
+	for _, revealing := range sizeRevealingTypes {
+		if t == revealing {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputWeight is the weight of an output of this type, false for the types wallets don't pay to
+func (t ScriptType) OutputWeight() (int64, bool) {
+	scriptSize, ok := outputScriptSizes[t]
+	return (8 + 1 + scriptSize) * WITNESS_SCALE_FACTOR, ok
+}
+
+// IsWitnessProgram tells whether outputs of this type are native segwit outputs
+func (t ScriptType) IsWitnessProgram() bool {
+	switch t {
+	case ScriptType_P2WKH, ScriptType_P2WSH, ScriptType_P2TR, ScriptType_WitnessUnknown, ScriptType_Anchor:
+		return true
+	}
+	return false
+}
+
+// SpendsWithWitness tells whether inputs spending outputs of this type have a witness, p2sh is assumed to wrap p2wkh
+func (t ScriptType) SpendsWithWitness() bool {
+	return t.IsWitnessProgram() || t == ScriptType_P2SH
+}
@@ -0,0 +1,9 @@
+package notifier
+
+import "context"
+
+//go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=notifier
+type Interface interface {
+	Start(ctx context.Context)
+	Blocks() <-chan string
+}
@@ -0,0 +1,135 @@
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/zmq"
+)
+
+const (
+	TOPIC_HASHBLOCK = "hashblock"
+	TOPIC_RAWBLOCK  = "rawblock"
+
+	// QUIET_TIMEOUT is how long the socket may stay silent before it's reconnected, polling covers the gap
+	QUIET_TIMEOUT       = 30 * time.Minute
+	RECONNECT_DELAY     = 5 * time.Second
+	MAX_RECONNECT_DELAY = time.Minute
+
+	BLOCK_HEADER_SIZE = 80
+)
+
+// TOPICS are subscribed to, bitcoind publishes on whichever of them it was configured with
+var TOPICS = []string{TOPIC_HASHBLOCK, TOPIC_RAWBLOCK}
+
+// server subscribes to bitcoind's -zmqpubhashblock or -zmqpubrawblock endpoint
+type server struct {
+	endpoint     string
+	blocks       chan string
+	quietTimeout time.Duration
+}
+
+func New(endpoint string) Interface {
+	return &server{
+		endpoint: endpoint,
+		// a single pending announcement is enough to trigger a sync, later ones are coalesced
+		blocks:       make(chan string, 1),
+		quietTimeout: QUIET_TIMEOUT,
+	}
+}
+
+// Blocks receives the hash of every announced block
+func (s server) Blocks() <-chan string {
+	return s.blocks
+}
+
+// Start keeps a subscription open in the background, reconnecting with backoff, until ctx is done
+func (s server) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+func (s server) run(ctx context.Context) {
+	delay := RECONNECT_DELAY
+	for ctx.Err() == nil {
+		subscriber, err := zmq.Dial(ctx, s.endpoint, TOPICS...)
+		if err != nil {
+			log.Printf("[warning] failed to connect to zmq endpoint %s with error: %s, retrying in %s\n", s.endpoint, err.Error(), delay)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > MAX_RECONNECT_DELAY {
+				delay = MAX_RECONNECT_DELAY
+			}
+			continue
+		}
+
+		log.Println("[debug] subscribed to block notifications at ", s.endpoint)
+		delay = RECONNECT_DELAY
+		s.listen(ctx, subscriber)
+		subscriber.Close()
+	}
+}
+
+// listen forwards announcements until the connection fails, goes quiet or ctx is done
+func (s server) listen(ctx context.Context, subscriber *zmq.Subscriber) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			subscriber.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		parts, err := subscriber.Receive(s.quietTimeout)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("[debug] no block announced for %s, reconnecting to %s\n", s.quietTimeout, s.endpoint)
+			} else if ctx.Err() == nil {
+				log.Println("[warning] zmq subscription failed with error: ", err.Error())
+			}
+			return
+		}
+		if len(parts) < 2 {
+			continue
+		}
+
+		hash := blockHash(string(parts[0]), parts[1])
+		if hash == "" {
+			continue
+		}
+		select {
+		case s.blocks <- hash:
+		default:
+		}
+	}
+}
+
+// blockHash returns the hash of the announced block in RPC byte order
+func blockHash(topic string, body []byte) string {
+	switch topic {
+	case TOPIC_HASHBLOCK:
+		// already in RPC byte order
+		return hex.EncodeToString(body)
+	case TOPIC_RAWBLOCK:
+		if len(body) < BLOCK_HEADER_SIZE {
+			return ""
+		}
+		first := sha256.Sum256(body[:BLOCK_HEADER_SIZE])
+		hash := sha256.Sum256(first[:])
+		for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+			hash[i], hash[j] = hash[j], hash[i]
+		}
+		return hex.EncodeToString(hash[:])
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/zmq/zmqtest"
+)
+
+// GENESIS_HEADER hashes to the mainnet genesis block hash
+const (
+	GENESIS_HEADER = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c"
+	GENESIS_HASH   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+)
+
+func publish(t *testing.T, conn *zmqtest.Conn, topic string, body []byte) {
+	if err := conn.Send([]byte(topic), body, []byte{1, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func announced(t *testing.T, n Interface) string {
+	select {
+	case hash := <-n.Blocks():
+		return hash
+	case <-time.After(5 * time.Second):
+		t.Fatal("no block announced")
+		return ""
+	}
+}
+
+func startNotifier(t *testing.T, endpoint string, quietTimeout time.Duration) Interface {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	n := &server{endpoint: endpoint, blocks: make(chan string, 1), quietTimeout: quietTimeout}
+	n.Start(ctx)
+	return n
+}
+
+func TestAnnouncements(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	n := startNotifier(t, p.Endpoint(), QUIET_TIMEOUT)
+	conn := p.Accept(t)
+	topics, err := conn.Subscriptions(len(TOPICS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(topics, ",") != strings.Join(TOPICS, ",") {
+		t.Fatalf("unexpected subscriptions %v", topics)
+	}
+
+	hash, _ := hex.DecodeString(GENESIS_HASH)
+	publish(t, conn, TOPIC_HASHBLOCK, hash)
+	if got := announced(t, n); got != GENESIS_HASH {
+		t.Fatalf("hashblock announced %s", got)
+	}
+
+	header, _ := hex.DecodeString(GENESIS_HEADER)
+	publish(t, conn, TOPIC_RAWBLOCK, append(header, 0x01))
+	if got := announced(t, n); got != GENESIS_HASH {
+		t.Fatalf("rawblock announced %s", got)
+	}
+}
+
+func TestReconnectsAfterPublisherDrops(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	n := startNotifier(t, p.Endpoint(), QUIET_TIMEOUT)
+	dropped := p.Accept(t)
+	// drop it once subscribed, an earlier drop fails the dial itself
+	if _, err := dropped.Subscriptions(len(TOPICS)); err != nil {
+		t.Fatal(err)
+	}
+	dropped.Close()
+
+	conn := p.Accept(t)
+	hash, _ := hex.DecodeString(GENESIS_HASH)
+	publish(t, conn, TOPIC_HASHBLOCK, hash)
+	if got := announced(t, n); got != GENESIS_HASH {
+		t.Fatalf("announced %s after reconnecting", got)
+	}
+}
+
+// A quiet socket announces nothing, syncing then relies on the synchronizer's polling while the notifier reconnects
+func TestQuietSocketFallsBackToPolling(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	n := startNotifier(t, p.Endpoint(), 100*time.Millisecond)
+	first := p.Accept(t)
+	defer first.Close()
+
+	second := p.Accept(t)
+	defer second.Close()
+	select {
+	case hash := <-n.Blocks():
+		t.Fatalf("a quiet socket announced %s", hash)
+	default:
+	}
+
+	hash, _ := hex.DecodeString(GENESIS_HASH)
+	publish(t, second, TOPIC_HASHBLOCK, hash)
+	if got := announced(t, n); got != GENESIS_HASH {
+		t.Fatalf("announced %s after reconnecting", got)
+	}
+}
+
+func TestUnreachableEndpointAnnouncesNothing(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := "tcp://" + listener.Addr().String()
+	listener.Close()
+
+	n := startNotifier(t, endpoint, QUIET_TIMEOUT)
+	select {
+	case hash := <-n.Blocks():
+		t.Fatalf("announced %s without a publisher", hash)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,172 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	MAGIC = "psbt\xff"
+
+	// key types of BIP174 and BIP371, the global map's and the input map's
+	PSBT_GLOBAL_UNSIGNED_TX      = 0x00
+	PSBT_IN_NON_WITNESS_UTXO     = 0x00
+	PSBT_IN_WITNESS_UTXO         = 0x01
+	PSBT_IN_REDEEM_SCRIPT        = 0x04
+	PSBT_IN_BIP32_DERIVATION     = 0x06
+	PSBT_IN_TAP_BIP32_DERIVATION = 0x16
+	PSBT_IN_TAP_INTERNAL_KEY     = 0x17
+
+	TX_VERSION = 2
+	// SEQUENCE_RBF signals replaceability (BIP125) and enables the locktime
+	SEQUENCE_RBF = 0xfffffffd
+)
+
+// Input is an outpoint with what signers need to know about the output it spends
+type Input struct {
+	TxID   string
+	Vout   uint32
+	Amount int64
+	Script []byte
+	// WitnessUtxo is set for segwit inputs, the output is then given as amount and script
+	WitnessUtxo bool
+	// NonWitnessUtxo is the serialized transaction of the output, needed to sign legacy inputs
+	NonWitnessUtxo []byte
+	// RedeemScript is the script a p2sh output commits to, when it's known
+	RedeemScript []byte
+	// Derivation tells signers where the key of the output comes from, nil when it's unknown
+	Derivation *Derivation
+}
+
+// Derivation is a public key with the fingerprint of its master key and its path from it. The key of a p2tr input is
+// its internal key, it's written with the taproot records
+type Derivation struct {
+	PubKey      []byte // compressed
+	Fingerprint []byte
+	Path        []uint32
+	Taproot     bool
+}
+
+type Output struct {
+	Amount int64
+	Script []byte
+}
+
+// Create serializes a version 0 PSBT of an unsigned transaction spending `inputs` to `outputs`
+func Create(inputs []*Input, outputs []*Output, lockTime uint32) ([]byte, error) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return nil, errors.New("a transaction needs inputs and outputs")
+	}
+
+	tx := &bytes.Buffer{}
+	binary.Write(tx, binary.LittleEndian, int32(TX_VERSION))
+	writeVarInt(tx, uint64(len(inputs)))
+	for _, input := range inputs {
+		txid, err := hex.DecodeString(input.TxID)
+		if err != nil || len(txid) != 32 {
+			return nil, fmt.Errorf("invalid txid %q", input.TxID)
+		}
+		tx.Write(reverse(txid))
+		binary.Write(tx, binary.LittleEndian, input.Vout)
+		writeVarInt(tx, 0) // empty scriptSig
+		binary.Write(tx, binary.LittleEndian, uint32(SEQUENCE_RBF))
+	}
+	writeVarInt(tx, uint64(len(outputs)))
+	for _, output := range outputs {
+		writeTxOut(tx, output.Amount, output.Script)
+	}
+	binary.Write(tx, binary.LittleEndian, lockTime)
+
+	psbt := &bytes.Buffer{}
+	psbt.WriteString(MAGIC)
+	writeKeyValue(psbt, []byte{PSBT_GLOBAL_UNSIGNED_TX}, tx.Bytes())
+	psbt.WriteByte(0x00)
+	for _, input := range inputs {
+		if input.NonWitnessUtxo != nil {
+			writeKeyValue(psbt, []byte{PSBT_IN_NON_WITNESS_UTXO}, input.NonWitnessUtxo)
+		}
+		if input.WitnessUtxo {
+			txOut := &bytes.Buffer{}
+			writeTxOut(txOut, input.Amount, input.Script)
+			writeKeyValue(psbt, []byte{PSBT_IN_WITNESS_UTXO}, txOut.Bytes())
+		}
+		if input.RedeemScript != nil {
+			writeKeyValue(psbt, []byte{PSBT_IN_REDEEM_SCRIPT}, input.RedeemScript)
+		}
+		if err := writeDerivation(psbt, input.Derivation); err != nil {
+			return nil, fmt.Errorf("invalid derivation of %s:%d: %w", input.TxID, input.Vout, err)
+		}
+		psbt.WriteByte(0x00)
+	}
+	for range outputs {
+		psbt.WriteByte(0x00)
+	}
+	return psbt.Bytes(), nil
+}
+
+// writeDerivation writes PSBT_IN_BIP32_DERIVATION, or PSBT_IN_TAP_BIP32_DERIVATION without leaf hashes and
+// PSBT_IN_TAP_INTERNAL_KEY for a taproot key
+func writeDerivation(buffer *bytes.Buffer, derivation *Derivation) error {
+	if derivation == nil {
+		return nil
+	}
+	if len(derivation.PubKey) != 33 || len(derivation.Fingerprint) != 4 {
+		return errors.New("a derivation needs a compressed key and a 4 bytes fingerprint")
+	}
+	origin := &bytes.Buffer{}
+	origin.Write(derivation.Fingerprint)
+	for _, index := range derivation.Path {
+		binary.Write(origin, binary.LittleEndian, index)
+	}
+
+	if !derivation.Taproot {
+		writeKeyValue(buffer, append([]byte{PSBT_IN_BIP32_DERIVATION}, derivation.PubKey...), origin.Bytes())
+		return nil
+	}
+	xOnly := derivation.PubKey[1:]
+	writeKeyValue(buffer, append([]byte{PSBT_IN_TAP_BIP32_DERIVATION}, xOnly...), append([]byte{0}, origin.Bytes()...))
+	writeKeyValue(buffer, []byte{PSBT_IN_TAP_INTERNAL_KEY}, xOnly)
+	return nil
+}
+
+func writeTxOut(buffer *bytes.Buffer, amount int64, script []byte) {
+	binary.Write(buffer, binary.LittleEndian, amount)
+	writeVarInt(buffer, uint64(len(script)))
+	buffer.Write(script)
+}
+
+func writeKeyValue(buffer *bytes.Buffer, key []byte, value []byte) {
+	writeVarInt(buffer, uint64(len(key)))
+	buffer.Write(key)
+	writeVarInt(buffer, uint64(len(value)))
+	buffer.Write(value)
+}
+
+// writeVarInt writes bitcoin's CompactSize encoding of `n`
+func writeVarInt(buffer *bytes.Buffer, n uint64) {
+	switch {
+	case n < 0xfd:
+		buffer.WriteByte(byte(n))
+	case n <= 0xffff:
+		buffer.WriteByte(0xfd)
+		binary.Write(buffer, binary.LittleEndian, uint16(n))
+	case n <= 0xffffffff:
+		buffer.WriteByte(0xfe)
+		binary.Write(buffer, binary.LittleEndian, uint32(n))
+	default:
+		buffer.WriteByte(0xff)
+		binary.Write(buffer, binary.LittleEndian, n)
+	}
+}
+
+// reverse returns a reversed copy, txids are displayed in the reverse of their serialized byte order
+func reverse(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
@@ -0,0 +1,180 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	TXID = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+	// the key of the first receive address of BIP84, at m/84'/0'/0'/0/0 of the master key 73c5da0a
+	PUBKEY      = "0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c"
+	FINGERPRINT = "73c5da0a"
+	HARDENED    = 1 << 31
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// readMap reads a key-value map of a PSBT up to its separator, values by hex key
+func readMap(t *testing.T, r *bytes.Reader) map[string]string {
+	t.Helper()
+	records := map[string]string{}
+	for {
+		key := readBytes(t, r)
+		if len(key) == 0 {
+			return records
+		}
+		if _, ok := records[hex.EncodeToString(key)]; ok {
+			t.Fatalf("duplicate key %x", key)
+		}
+		records[hex.EncodeToString(key)] = hex.EncodeToString(readBytes(t, r))
+	}
+}
+
+func readBytes(t *testing.T, r *bytes.Reader) []byte {
+	t.Helper()
+	size, err := r.ReadByte()
+	if err != nil {
+		t.Fatalf("truncated psbt: %v", err)
+	}
+	if size >= 0xfd {
+		t.Fatalf("unexpected size prefix %x", size)
+	}
+	data := make([]byte, size)
+	if _, err := r.Read(data); err != nil && size > 0 {
+		t.Fatalf("truncated psbt: %v", err)
+	}
+	return data
+}
+
+func origin(path ...uint32) string {
+	value := &bytes.Buffer{}
+	value.Write([]byte{0x73, 0xc5, 0xda, 0x0a})
+	for _, index := range path {
+		binary.Write(value, binary.LittleEndian, index)
+	}
+	return hex.EncodeToString(value.Bytes())
+}
+
+func TestCreateEmptyTransaction(t *testing.T) {
+	txid := strings.Repeat("00", 31) + "01"
+	serialized, err := Create([]*Input{{TxID: txid, Vout: 0}}, []*Output{{Amount: 1000, Script: []byte{0x51}}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "70736274ff" + "01" + "00" + "3d" +
+		"02000000" + "01" + "01" + strings.Repeat("00", 31) + "00000000" + "00" + "fdffffff" +
+		"01" + "e803000000000000" + "01" + "51" + "00000000" +
+		"00" + // end of the global map
+		"00" + // the input map
+		"00" // the output map
+	if hex.EncodeToString(serialized) != expected {
+		t.Fatalf("expected %s, got %x", expected, serialized)
+	}
+}
+
+func TestCreateInputRecords(t *testing.T) {
+	pubKey := mustDecodeHex(t, PUBKEY)
+	fingerprint := mustDecodeHex(t, FINGERPRINT)
+	p2wpkh := mustDecodeHex(t, "0014c0cebcd6c3d3ca8c75dc5ec62ebe55330ef910e2")
+	p2tr := mustDecodeHex(t, "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c")
+	redeemScript := mustDecodeHex(t, "0014c0cebcd6c3d3ca8c75dc5ec62ebe55330ef910e2")
+	p2sh := mustDecodeHex(t, "a914336caa13e08b96080a32b5d818d59b4ab3b3674287")
+	previousTx := mustDecodeHex(t, "0200000000010000000000")
+	path := []uint32{84 + HARDENED, HARDENED, HARDENED, 0, 0}
+
+	inputs := []*Input{
+		{TxID: TXID, Vout: 0, Amount: 10000, Script: p2wpkh, WitnessUtxo: true,
+			Derivation: &Derivation{PubKey: pubKey, Fingerprint: fingerprint, Path: path}},
+		{TxID: TXID, Vout: 1, Amount: 20000, Script: p2tr, WitnessUtxo: true,
+			Derivation: &Derivation{PubKey: pubKey, Fingerprint: fingerprint, Path: path, Taproot: true}},
+		{TxID: TXID, Vout: 2, Amount: 30000, Script: p2sh, WitnessUtxo: true, NonWitnessUtxo: previousTx, RedeemScript: redeemScript},
+		{TxID: TXID, Vout: 3, Amount: 40000, Script: p2sh, NonWitnessUtxo: previousTx},
+	}
+	outputs := []*Output{{Amount: 90000, Script: p2wpkh}}
+	serialized, err := Create(inputs, outputs, 800000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bytes.NewReader(serialized)
+	magic := make([]byte, len(MAGIC))
+	r.Read(magic)
+	if string(magic) != MAGIC {
+		t.Fatalf("unexpected magic %x", magic)
+	}
+	global := readMap(t, r)
+	tx := mustDecodeHex(t, global["00"])
+	if len(global) != 1 || binary.LittleEndian.Uint32(tx) != TX_VERSION || binary.LittleEndian.Uint32(tx[len(tx)-4:]) != 800000 {
+		t.Fatalf("unexpected global map %v", global)
+	}
+	if tx[4] != byte(len(inputs)) || hex.EncodeToString(tx[5:37]) != hex.EncodeToString(reverse(mustDecodeHex(t, TXID))) {
+		t.Fatalf("unexpected unsigned transaction %x", tx)
+	}
+
+	txOut := func(amount int64, script []byte) string {
+		buffer := &bytes.Buffer{}
+		writeTxOut(buffer, amount, script)
+		return hex.EncodeToString(buffer.Bytes())
+	}
+	expected := []map[string]string{
+		{
+			"01":          txOut(10000, p2wpkh),
+			"06" + PUBKEY: origin(path...),
+		},
+		{
+			"01":              txOut(20000, p2tr),
+			"16" + PUBKEY[2:]: "00" + origin(path...),
+			"17":              PUBKEY[2:],
+		},
+		{
+			"00": hex.EncodeToString(previousTx),
+			"01": txOut(30000, p2sh),
+			"04": hex.EncodeToString(redeemScript),
+		},
+		{
+			"00": hex.EncodeToString(previousTx),
+		},
+	}
+	for index, records := range expected {
+		if got := readMap(t, r); fmt.Sprint(got) != fmt.Sprint(records) {
+			t.Errorf("input %d: expected %v, got %v", index, records, got)
+		}
+	}
+	if got := readMap(t, r); len(got) != 0 {
+		t.Errorf("expected an empty output map, got %v", got)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d trailing bytes", r.Len())
+	}
+}
+
+func TestCreateRefusesInvalidInputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []*Input
+	}{
+		{"no inputs", nil},
+		{"short txid", []*Input{{TxID: TXID[2:]}}},
+		{"uncompressed derivation key", []*Input{{TxID: TXID, Derivation: &Derivation{PubKey: make([]byte, 65), Fingerprint: make([]byte, 4)}}}},
+		{"short fingerprint", []*Input{{TxID: TXID, Derivation: &Derivation{PubKey: make([]byte, 33), Fingerprint: make([]byte, 3)}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Create(test.inputs, []*Output{{Amount: 1000, Script: []byte{0x51}}}, 0); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	OP_DUP         = 0x76
+	OP_EQUAL       = 0x87
+	OP_EQUALVERIFY = 0x88
+	OP_HASH160     = 0xa9
+)
+
+// AddressScript returns the scriptPubKey paying to a P2PKH, P2SH or segwit address of `network`
+func AddressScript(address string, network *Network) ([]byte, error) {
+	if strings.HasPrefix(strings.ToLower(address), network.Bech32HRP+"1") {
+		version, program, err := DecodeSegwitAddress(address, network)
+		if err != nil {
+			return nil, err
+		}
+		opcode := byte(OP_0)
+		if version > 0 {
+			opcode = OP_1 + version - 1
+		}
+		return append([]byte{opcode, byte(len(program))}, program...), nil
+	}
+
+	payload, err := Base58CheckDecode(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 21 {
+		return nil, errors.New("invalid address length")
+	}
+	switch payload[0] {
+	case network.PubKeyHashPrefix:
+		return append(append([]byte{OP_DUP, OP_HASH160, 20}, payload[1:]...), OP_EQUALVERIFY, OP_CHECKSIG), nil
+	case network.ScriptHashPrefix:
+		return append(append([]byte{OP_HASH160, 20}, payload[1:]...), OP_EQUAL), nil
+	}
+	return nil, fmt.Errorf("address is not for %s", network.Name)
+}
@@ -0,0 +1,77 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const BASE58_ALPHABET = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58CheckEncode encodes `version` and `payload` followed by the first 4 bytes of their double SHA256
+func Base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	data = append(data, DoubleSha256(data)[:4]...)
+	return Base58Encode(data)
+}
+
+func Base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(int64(len(BASE58_ALPHABET)))
+	remainder := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, remainder)
+		encoded = append(encoded, BASE58_ALPHABET[remainder.Int64()])
+	}
+	// every leading zero byte is written as the first symbol
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, BASE58_ALPHABET[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// Base58CheckDecode returns the payload of a Base58Check string, version bytes included, after verifying its checksum
+func Base58CheckDecode(encoded string) ([]byte, error) {
+	data, err := Base58Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errors.New("base58check string too short")
+	}
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(DoubleSha256(payload)[:4], checksum) {
+		return nil, errors.New("invalid base58check checksum")
+	}
+	return payload, nil
+}
+
+func Base58Decode(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(int64(len(BASE58_ALPHABET)))
+	for _, c := range encoded {
+		digit := strings.IndexRune(BASE58_ALPHABET, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	var zeros int
+	for zeros < len(encoded) && encoded[zeros] == BASE58_ALPHABET[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
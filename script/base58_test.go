@@ -0,0 +1,57 @@
+package script
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		data    string
+		encoded string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"00000000", "1111"},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	}
+	for _, test := range tests {
+		t.Run(test.encoded, func(t *testing.T) {
+			data, _ := hex.DecodeString(test.data)
+			if encoded := Base58Encode(data); encoded != test.encoded {
+				t.Errorf("expected %s, got %s", test.encoded, encoded)
+			}
+			decoded, err := Base58Decode(test.encoded)
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Errorf("expected %x, got %x with error %v", data, decoded, err)
+			}
+		})
+	}
+
+	if _, err := Base58Decode("1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE90"); err == nil {
+		t.Error("expected an error for a character outside the alphabet")
+	}
+}
+
+func TestBase58Check(t *testing.T) {
+	payload, err := Base58CheckDecode("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(payload) != "0062e907b15cbf27d5425399ebf6f0fb50ebb88f18" {
+		t.Errorf("unexpected payload %x", payload)
+	}
+	if encoded := Base58CheckEncode(payload[0], payload[1:]); encoded != "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa" {
+		t.Errorf("expected the address back, got %s", encoded)
+	}
+
+	for _, encoded := range []string{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", "1111", "0A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"} {
+		if _, err := Base58CheckDecode(encoded); err == nil {
+			t.Errorf("expected %s to be rejected", encoded)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	BECH32_CHARSET = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// the constants the checksum is xored with, BIP173 for witness version 0 and BIP350 for later versions
+	BECH32_CONST  = 1
+	BECH32M_CONST = 0x2bc830a3
+)
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// SegwitAddress encodes a witness program, bech32 for version 0 and bech32m for later versions
+func SegwitAddress(network *Network, version byte, program []byte) (string, error) {
+	if version > 16 || len(program) < 2 || len(program) > 40 {
+		return "", errors.New("invalid witness program")
+	}
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	checksumConst := uint32(BECH32_CONST)
+	if version > 0 {
+		checksumConst = BECH32M_CONST
+	}
+	return bech32Encode(network.Bech32HRP, append([]byte{version}, data...), checksumConst), nil
+}
+
+func bech32Encode(hrp string, data []byte, checksumConst uint32) string {
+	values := append(hrpExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ checksumConst
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte('1')
+	for _, value := range data {
+		encoded.WriteByte(BECH32_CHARSET[value])
+	}
+	for i := 0; i < 6; i++ {
+		encoded.WriteByte(BECH32_CHARSET[(polymod>>uint(5*(5-i)))&31])
+	}
+	return encoded.String()
+}
+
+func hrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32Polymod(values []byte) uint32 {
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i, generator := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator
+			}
+		}
+	}
+	return checksum
+}
+
+// convertBits regroups `data` from `fromBits` to `toBits` bits per byte
+func convertBits(data []byte, fromBits uint, toBits uint, pad bool) ([]byte, error) {
+	var accumulator, bits uint
+	maxValue := uint(1)<<toBits - 1
+	var converted []byte
+	for _, value := range data {
+		if uint(value)>>fromBits != 0 {
+			return nil, errors.New("invalid data range")
+		}
+		accumulator = accumulator<<fromBits | uint(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(accumulator>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(accumulator<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || accumulator<<(toBits-bits)&maxValue != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return converted, nil
+}
+
+// DecodeSegwitAddress returns the witness version and program of a bech32 (version 0) or bech32m address of `network`
+func DecodeSegwitAddress(address string, network *Network) (byte, []byte, error) {
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return 0, nil, errors.New("mixed case bech32 address")
+	}
+	address = strings.ToLower(address)
+	separator := strings.LastIndexByte(address, '1')
+	if separator < 1 || separator+7 > len(address) || len(address) > 90 {
+		return 0, nil, errors.New("malformed bech32 address")
+	}
+	if address[:separator] != network.Bech32HRP {
+		return 0, nil, fmt.Errorf("address is not for %s", network.Name)
+	}
+
+	var data []byte
+	for _, c := range address[separator+1:] {
+		value := strings.IndexRune(BECH32_CHARSET, c)
+		if value < 0 {
+			return 0, nil, fmt.Errorf("invalid bech32 character %q", c)
+		}
+		data = append(data, byte(value))
+	}
+	if len(data) < 7 {
+		return 0, nil, errors.New("malformed bech32 address")
+	}
+	version := data[0]
+	checksumConst := uint32(BECH32_CONST)
+	if version > 0 {
+		checksumConst = BECH32M_CONST
+	}
+	if bech32Polymod(append(hrpExpand(network.Bech32HRP), data...)) != checksumConst {
+		return 0, nil, errors.New("invalid bech32 checksum")
+	}
+
+	program, err := convertBits(data[1:len(data)-6], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version > 16 || len(program) < 2 || len(program) > 40 || version == 0 && len(program) != 20 && len(program) != 32 {
+		return 0, nil, errors.New("invalid witness program")
+	}
+	return version, program, nil
+}
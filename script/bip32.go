@@ -0,0 +1,161 @@
+package script
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	EXTENDED_KEY_SIZE = 78
+	// HARDENED_INDEX is the first index of hardened children, which can't be derived from a public key
+	HARDENED_INDEX = 1 << 31
+)
+
+// AddressType is how the keys derived from an extended public key are paid to
+type AddressType string
+
+const (
+	AddressType_P2PKH       AddressType = "p2pkh"       // BIP44
+	AddressType_P2SH_P2WPKH AddressType = "p2sh-p2wpkh" // BIP49
+	AddressType_P2WPKH      AddressType = "p2wpkh"      // BIP84
+	AddressType_P2TR        AddressType = "p2tr"        // BIP86
+)
+
+func (t AddressType) IsValid() bool {
+	switch t {
+	case AddressType_P2PKH, AddressType_P2SH_P2WPKH, AddressType_P2WPKH, AddressType_P2TR:
+		return true
+	}
+	return false
+}
+
+type extendedKeyVersion struct {
+	mainnet     bool
+	addressType AddressType
+}
+
+// extendedPubKeyVersions maps the version bytes of xpub and tpub, and of their SLIP-132 variants, to the chain
+// and the address type they're used for
+var extendedPubKeyVersions = map[uint32]extendedKeyVersion{
+	0x0488b21e: {mainnet: true, addressType: AddressType_P2PKH},        // xpub
+	0x049d7cb2: {mainnet: true, addressType: AddressType_P2SH_P2WPKH},  // ypub
+	0x04b24746: {mainnet: true, addressType: AddressType_P2WPKH},       // zpub
+	0x043587cf: {mainnet: false, addressType: AddressType_P2PKH},       // tpub
+	0x044a5262: {mainnet: false, addressType: AddressType_P2SH_P2WPKH}, // upub
+	0x045f1cf6: {mainnet: false, addressType: AddressType_P2WPKH},      // vpub
+}
+
+// ExtendedPubKey is a BIP32 extended public key
+type ExtendedPubKey struct {
+	// AddressType is the one implied by the version bytes
+	AddressType AddressType
+	Depth       byte
+	key         *point
+	chainCode   []byte
+}
+
+// ParseExtendedPubKey decodes a Base58Check encoded extended public key of `network`
+func ParseExtendedPubKey(encoded string, network *Network) (*ExtendedPubKey, error) {
+	data, err := Base58CheckDecode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != EXTENDED_KEY_SIZE {
+		return nil, fmt.Errorf("extended key has %d bytes instead of %d", len(data), EXTENDED_KEY_SIZE)
+	}
+
+	version, ok := extendedPubKeyVersions[binary.BigEndian.Uint32(data[:4])]
+	if !ok {
+		return nil, errors.New("not an extended public key, private keys are not accepted")
+	}
+	if version.mainnet != (network == Mainnet) {
+		return nil, fmt.Errorf("extended public key is not for %s", network.Name)
+	}
+	key, err := parsePubKey(data[45:])
+	if err != nil || len(data[45:]) != COMPRESSED_PUBKEY_SIZE {
+		return nil, errors.New("extended public key holds an invalid key")
+	}
+
+	return &ExtendedPubKey{
+		AddressType: version.addressType,
+		Depth:       data[4],
+		key:         key,
+		chainCode:   data[13:45],
+	}, nil
+}
+
+// Child derives the non-hardened child `index` (BIP32 CKDpub)
+func (k *ExtendedPubKey) Child(index uint32) (*ExtendedPubKey, error) {
+	if index >= HARDENED_INDEX {
+		return nil, errors.New("hardened children can't be derived from a public key")
+	}
+	data := make([]byte, COMPRESSED_PUBKEY_SIZE+4)
+	copy(data, k.key.compressed())
+	binary.BigEndian.PutUint32(data[COMPRESSED_PUBKEY_SIZE:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	key, err := tweakAdd(k.key, new(big.Int).SetBytes(sum[:32]))
+	if err != nil {
+		return nil, fmt.Errorf("child %d is invalid: %w", index, err)
+	}
+	return &ExtendedPubKey{
+		AddressType: k.AddressType,
+		Depth:       k.Depth + 1,
+		key:         key,
+		chainCode:   sum[32:],
+	}, nil
+}
+
+// Derive follows `path` from this key
+func (k *ExtendedPubKey) Derive(path []uint32) (*ExtendedPubKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Address encodes the output paying to the key the way `addressType` does
+func (k *ExtendedPubKey) Address(addressType AddressType, network *Network) (string, error) {
+	pubKey := k.key.compressed()
+	switch addressType {
+	case AddressType_P2PKH:
+		return PubKeyHashAddress(pubKey, network), nil
+	case AddressType_P2SH_P2WPKH:
+		redeemScript := append([]byte{OP_0, 20}, Hash160(pubKey)...)
+		return Base58CheckEncode(network.ScriptHashPrefix, Hash160(redeemScript)), nil
+	case AddressType_P2WPKH:
+		return SegwitAddress(network, 0, Hash160(pubKey))
+	case AddressType_P2TR:
+		outputKey, err := taprootOutputKey(k.key)
+		if err != nil {
+			return "", err
+		}
+		return SegwitAddress(network, 1, outputKey)
+	}
+	return "", fmt.Errorf("unknown address type %q", addressType)
+}
+
+// taprootOutputKey tweaks an internal key without script path, as BIP86 specifies
+func taprootOutputKey(internalKey *point) ([]byte, error) {
+	evenKey, err := liftX(internalKey.x, false)
+	if err != nil {
+		return nil, err
+	}
+	tweak := TaggedHash("TapTweak", evenKey.xOnly())
+	outputKey, err := tweakAdd(evenKey, new(big.Int).SetBytes(tweak))
+	if err != nil {
+		return nil, err
+	}
+	return outputKey.xOnly(), nil
+}
@@ -0,0 +1,113 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+func expectKey(t *testing.T, key *ExtendedPubKey, encoded string) {
+	t.Helper()
+	expected, err := ParseExtendedPubKey(encoded, Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Depth != expected.Depth || !bytes.Equal(key.key.compressed(), expected.key.compressed()) || !bytes.Equal(key.chainCode, expected.chainCode) {
+		t.Fatalf("expected %s, got depth %d, key %x and chain code %x", encoded, key.Depth, key.key.compressed(), key.chainCode)
+	}
+}
+
+func TestChildKeyDerivation(t *testing.T) {
+	// test vector 1 of BIP32, the public derivation of m/0H/1 and m/0H/1/2H/2
+	tests := []struct {
+		parent string
+		index  uint32
+		child  string
+	}{
+		{
+			"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+			1,
+			"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+		},
+		{
+			"xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+			2,
+			"xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+		},
+	}
+	for _, test := range tests {
+		parent, err := ParseExtendedPubKey(test.parent, Mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := parent.Child(test.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectKey(t, child, test.child)
+	}
+}
+
+func TestHardenedChildIsRefused(t *testing.T) {
+	parent, err := ParseExtendedPubKey("xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parent.Child(HARDENED_INDEX); err == nil {
+		t.Fatal("expected an error for a hardened child")
+	}
+}
+
+func TestParseExtendedPubKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		network *Network
+		valid   bool
+	}{
+		{"xpub", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", Mainnet, true},
+		{"xpub on testnet", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", Testnet, false},
+		{"xprv", "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7", Mainnet, false},
+		{"bad checksum", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnx", Mainnet, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseExtendedPubKey(test.key, test.network); (err == nil) != test.valid {
+				t.Errorf("expected valid to be %t, got error %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestFirstAccountAddresses(t *testing.T) {
+	// the first receive address of the account 0 of the "abandon abandon ... about" mnemonic, from BIP49, BIP84 and BIP86
+	tests := []struct {
+		name        string
+		key         string
+		addressType AddressType
+		address     string
+	}{
+		{"bip49", "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP", "", "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{"bip84", "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs", "", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{"bip86", "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ", AddressType_P2TR, "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := ParseExtendedPubKey(test.key, Mainnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addressType := key.AddressType
+			if test.addressType != "" {
+				addressType = test.addressType
+			}
+			descriptor := XpubDescriptor(key, addressType)
+			addresses, err := descriptor.Addresses(descriptor.Chains[0], 0, 1, Mainnet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(addresses) != 1 || addresses[0].Address != test.address || addresses[0].Path != "0/0" {
+				t.Fatalf("expected %s at 0/0, got %+v", test.address, addresses[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package script
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// ChainCache keeps the addresses derived on the most recently scanned chains, scanning a wallet again only derives
+// the addresses past the ones already known
+type ChainCache struct {
+	mu     sync.Mutex
+	size   int
+	chains map[string]*list.Element
+	recent *list.List // of *cachedChain, the most recently used first
+}
+
+type cachedChain struct {
+	id        string
+	addresses []*DerivedAddress // from index 0, never modified once cached
+}
+
+// NewChainCache creates a cache of the addresses of at most `size` chains
+func NewChainCache(size int) *ChainCache {
+	return &ChainCache{size: size, chains: map[string]*list.Element{}, recent: list.New()}
+}
+
+// Addresses derives the addresses of `chain` at the indexes in [start, end) like Descriptor.Addresses
+func (c *ChainCache) Addresses(d *Descriptor, chain *DescriptorChain, start uint32, end uint32, network *Network) ([]*DerivedAddress, error) {
+	if !chain.Ranged || start >= end {
+		return d.Addresses(chain, start, end, network)
+	}
+	id := chainID(d, chain, network)
+
+	addresses := c.get(id)
+	if known := uint32(len(addresses)); known < end {
+		derived, err := d.Addresses(chain, known, end, network)
+		if err != nil {
+			return nil, err
+		}
+		// appending to a copy leaves the cached slice, which other requests may be reading, untouched
+		addresses = append(addresses[:known:known], derived...)
+		c.put(id, addresses)
+	}
+	return addresses[start:end], nil
+}
+
+// chainID identifies the addresses of a chain, by everything they're derived and labelled from
+func chainID(d *Descriptor, chain *DescriptorChain, network *Network) string {
+	return fmt.Sprintf("%s/%s/%x/%x/%x/%s/%v", network.Name, d.AddressType, d.Key.key.compressed(), d.Key.chainCode, d.Fingerprint, d.Origin, chain.Path)
+}
+
+func (c *ChainCache) get(id string) []*DerivedAddress {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.chains[id]
+	if !ok {
+		return nil
+	}
+	c.recent.MoveToFront(element)
+	return element.Value.(*cachedChain).addresses
+}
+
+func (c *ChainCache) put(id string, addresses []*DerivedAddress) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.chains[id]; ok {
+		cached := element.Value.(*cachedChain)
+		// a concurrent scan may have gone further
+		if len(addresses) > len(cached.addresses) {
+			cached.addresses = addresses
+		}
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.chains[id] = c.recent.PushFront(&cachedChain{id: id, addresses: addresses})
+	if c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.chains, oldest.Value.(*cachedChain).id)
+	}
+}
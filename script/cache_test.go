@@ -0,0 +1,48 @@
+package script
+
+import (
+	"fmt"
+	"testing"
+)
+
+func describe(addresses []*DerivedAddress) string {
+	var described []string
+	for _, address := range addresses {
+		described = append(described, address.Path+"="+address.Address)
+	}
+	return fmt.Sprint(described)
+}
+
+func TestChainCache(t *testing.T) {
+	key, err := ParseExtendedPubKey(BIP84_ACCOUNT, Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor := XpubDescriptor(key, key.AddressType)
+	expected, err := descriptor.Addresses(descriptor.Chains[0], 0, 30, Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewChainCache(1)
+	for _, r := range [][2]uint32{{0, 10}, {5, 20}, {20, 30}, {0, 30}} {
+		addresses, err := cache.Addresses(descriptor, descriptor.Chains[0], r[0], r[1], Mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if describe(addresses) != describe(expected[r[0]:r[1]]) {
+			t.Fatalf("unexpected addresses in [%d, %d)", r[0], r[1])
+		}
+	}
+
+	// the change chain evicts the receive chain
+	if _, err := cache.Addresses(descriptor, descriptor.Chains[1], 0, 1, Mainnet); err != nil {
+		t.Fatal(err)
+	}
+	if addresses := cache.get(chainID(descriptor, descriptor.Chains[0], Mainnet)); addresses != nil {
+		t.Fatalf("expected the receive chain to be evicted, got %d addresses", len(addresses))
+	}
+	if cache.recent.Len() != 1 || len(cache.chains) != 1 {
+		t.Fatalf("expected 1 cached chain, got %d", cache.recent.Len())
+	}
+}
@@ -0,0 +1,315 @@
+package script
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DESCRIPTOR_INPUT_CHARSET = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	DESCRIPTOR_CHECKSUM_SIZE = 8
+)
+
+var (
+	descriptorGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+	fingerprintPattern  = regexp.MustCompile("^[0-9a-fA-F]{8}$")
+)
+
+// Descriptor is the subset of BIP380 output descriptors paying to keys derived from one extended public key:
+// pkh(KEY), sh(wpkh(KEY)), wpkh(KEY) and tr(KEY), where KEY may have an origin, a path, a multipath step
+// (BIP389) and a trailing wildcard
+type Descriptor struct {
+	AddressType AddressType
+	Key         *ExtendedPubKey
+	// Origin is the path of Key from the master key, e.g. "m/84'/0'/0'", empty when unknown
+	Origin string
+	// Fingerprint is the one of the master key and OriginPath the indexes of Origin, nil when it's unknown
+	Fingerprint []byte
+	OriginPath  []uint32
+	Chains      []*DescriptorChain
+}
+
+// DescriptorChain is a path derived from the descriptor's key, the addresses are at Path/index when it's Ranged
+type DescriptorChain struct {
+	Path   []uint32
+	Ranged bool
+}
+
+// DerivedAddress is an address with the path of its key
+type DerivedAddress struct {
+	Address     string      `json:"address"`
+	Path        string      `json:"derivation_path"`
+	AddressType AddressType `json:"-"`
+	// PubKey is the compressed key paid to, the internal key of p2tr addresses
+	PubKey []byte `json:"-"`
+	// Fingerprint is the one of the master key and Indexes the path of PubKey from it, nil when the origin is unknown
+	Fingerprint []byte   `json:"-"`
+	Indexes     []uint32 `json:"-"`
+}
+
+// XpubDescriptor is the descriptor of the receive (0/*) and change (1/*) chains of an account key,
+// as used by BIP44, BIP49, BIP84 and BIP86 wallets
+func XpubDescriptor(key *ExtendedPubKey, addressType AddressType) *Descriptor {
+	return &Descriptor{
+		AddressType: addressType,
+		Key:         key,
+		Chains: []*DescriptorChain{
+			{Path: []uint32{0}, Ranged: true},
+			{Path: []uint32{1}, Ranged: true},
+		},
+	}
+}
+
+// ParseDescriptor decodes `descriptor`, verifying its checksum when it has one
+func ParseDescriptor(descriptor string, network *Network) (*Descriptor, error) {
+	if position := strings.LastIndexByte(descriptor, '#'); position >= 0 {
+		expected, err := DescriptorChecksum(descriptor[:position])
+		if err != nil {
+			return nil, err
+		}
+		if descriptor[position+1:] != expected {
+			return nil, fmt.Errorf("invalid descriptor checksum, expected %s", expected)
+		}
+		descriptor = descriptor[:position]
+	}
+
+	var addressType AddressType
+	var keyExpression string
+	for _, function := range []struct {
+		prefix      string
+		addressType AddressType
+	}{
+		{"pkh(", AddressType_P2PKH},
+		{"sh(wpkh(", AddressType_P2SH_P2WPKH},
+		{"wpkh(", AddressType_P2WPKH},
+		{"tr(", AddressType_P2TR},
+	} {
+		suffix := strings.Repeat(")", strings.Count(function.prefix, "("))
+		if strings.HasPrefix(descriptor, function.prefix) && strings.HasSuffix(descriptor, suffix) {
+			addressType = function.addressType
+			keyExpression = strings.TrimSuffix(strings.TrimPrefix(descriptor, function.prefix), suffix)
+			break
+		}
+	}
+	if addressType == "" {
+		return nil, errors.New("only pkh(), sh(wpkh()), wpkh() and tr() descriptors are supported")
+	}
+	if strings.ContainsAny(keyExpression, "(),") {
+		return nil, errors.New("descriptors with scripts or several keys are not supported")
+	}
+
+	parsed := &Descriptor{AddressType: addressType}
+	if strings.HasPrefix(keyExpression, "[") {
+		end := strings.IndexByte(keyExpression, ']')
+		if end < 0 {
+			return nil, errors.New("unterminated key origin")
+		}
+		if err := parsed.parseOrigin(keyExpression[1:end]); err != nil {
+			return nil, err
+		}
+		keyExpression = keyExpression[end+1:]
+	}
+
+	steps := strings.Split(keyExpression, "/")
+	key, err := ParseExtendedPubKey(steps[0], network)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor key: %w", err)
+	}
+	parsed.Key = key
+	if parsed.Chains, err = parseChains(steps[1:]); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// parseOrigin checks the fingerprint and the path of a key origin and sets them on the descriptor
+func (d *Descriptor) parseOrigin(origin string) error {
+	steps := strings.Split(origin, "/")
+	if !fingerprintPattern.MatchString(steps[0]) {
+		return errors.New("key origin must start with a fingerprint of 8 hex digits")
+	}
+	fingerprint, _ := hex.DecodeString(steps[0])
+
+	path := "m"
+	indexes := []uint32{}
+	for _, step := range steps[1:] {
+		hardened := strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h")
+		index, err := strconv.ParseUint(strings.TrimRight(step, "'h"), 10, 31)
+		if err != nil {
+			return fmt.Errorf("invalid key origin step %q", step)
+		}
+		path += "/" + strconv.FormatUint(index, 10)
+		if hardened {
+			path += "'"
+			index += HARDENED_INDEX
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	d.Origin, d.Fingerprint, d.OriginPath = path, fingerprint, indexes
+	return nil
+}
+
+// parseChains expands the steps after the key, a multipath step <a;b;...> gives one chain per index
+func parseChains(steps []string) ([]*DescriptorChain, error) {
+	chains := []*DescriptorChain{{}}
+	multipath := false
+	for position, step := range steps {
+		switch {
+		case step == "*":
+			if position != len(steps)-1 {
+				return nil, errors.New("the wildcard must be the last step")
+			}
+			for _, chain := range chains {
+				chain.Ranged = true
+			}
+		case strings.HasPrefix(step, "<") && strings.HasSuffix(step, ">"):
+			if multipath {
+				return nil, errors.New("only one multipath step is supported")
+			}
+			multipath = true
+			indexes := strings.Split(step[1:len(step)-1], ";")
+			if len(indexes) < 2 {
+				return nil, errors.New("a multipath step needs at least 2 indexes")
+			}
+			var expanded []*DescriptorChain
+			for _, value := range indexes {
+				index, err := parseStep(value)
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, &DescriptorChain{Path: append(append([]uint32{}, chains[0].Path...), index)})
+			}
+			chains = expanded
+		default:
+			index, err := parseStep(step)
+			if err != nil {
+				return nil, err
+			}
+			for _, chain := range chains {
+				chain.Path = append(chain.Path, index)
+			}
+		}
+	}
+	return chains, nil
+}
+
+func parseStep(step string) (uint32, error) {
+	if strings.HasSuffix(step, "'") || strings.HasSuffix(step, "h") {
+		return 0, errors.New("hardened steps can't be derived from an extended public key")
+	}
+	index, err := strconv.ParseUint(step, 10, 31)
+	if err != nil {
+		return 0, fmt.Errorf("invalid derivation step %q", step)
+	}
+	return uint32(index), nil
+}
+
+// Addresses derives the addresses of `chain` at the indexes in [start, end), a chain which isn't ranged only has one
+func (d *Descriptor) Addresses(chain *DescriptorChain, start uint32, end uint32, network *Network) ([]*DerivedAddress, error) {
+	chainKey, err := d.Key.Derive(chain.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []string
+	if d.Origin != "" {
+		steps = append(steps, d.Origin)
+	}
+	for _, index := range chain.Path {
+		steps = append(steps, strconv.FormatUint(uint64(index), 10))
+	}
+
+	if !chain.Ranged {
+		if start > 0 {
+			return nil, nil
+		}
+		address, err := d.derivedAddress(chainKey, steps, chain.Path, network)
+		if err != nil {
+			return nil, err
+		}
+		return []*DerivedAddress{address}, nil
+	}
+
+	var addresses []*DerivedAddress
+	for index := start; index < end; index++ {
+		key, err := chainKey.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		path := append(chain.Path[:len(chain.Path):len(chain.Path)], index)
+		address, err := d.derivedAddress(key, append(steps, strconv.FormatUint(uint64(index), 10)), path, network)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// derivedAddress describes the address of `key`, found at `path` from the descriptor's key
+func (d *Descriptor) derivedAddress(key *ExtendedPubKey, steps []string, path []uint32, network *Network) (*DerivedAddress, error) {
+	address, err := key.Address(d.AddressType, network)
+	if err != nil {
+		return nil, err
+	}
+	derived := &DerivedAddress{
+		Address:     address,
+		Path:        strings.Join(steps, "/"),
+		AddressType: d.AddressType,
+		PubKey:      key.key.compressed(),
+	}
+	if d.Fingerprint != nil {
+		derived.Fingerprint = d.Fingerprint
+		derived.Indexes = append(append([]uint32{}, d.OriginPath...), path...)
+	}
+	return derived, nil
+}
+
+// DescriptorChecksum computes the BIP380 checksum of a descriptor without one
+func DescriptorChecksum(descriptor string) (string, error) {
+	var symbols []uint64
+	var groups []uint64
+	for _, c := range descriptor {
+		position := strings.IndexRune(DESCRIPTOR_INPUT_CHARSET, c)
+		if position < 0 {
+			return "", fmt.Errorf("invalid descriptor character %q", c)
+		}
+		symbols = append(symbols, uint64(position&31))
+		groups = append(groups, uint64(position>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, DESCRIPTOR_CHECKSUM_SIZE)...)
+
+	checksum := uint64(1)
+	for _, value := range symbols {
+		top := checksum >> 35
+		checksum = (checksum&0x7ffffffff)<<5 ^ value
+		for i, generator := range descriptorGenerator {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator
+			}
+		}
+	}
+	checksum ^= 1
+
+	encoded := make([]byte, DESCRIPTOR_CHECKSUM_SIZE)
+	for i := range encoded {
+		encoded[i] = BECH32_CHARSET[(checksum>>uint(5*(DESCRIPTOR_CHECKSUM_SIZE-1-i)))&31]
+	}
+	return string(encoded), nil
+}
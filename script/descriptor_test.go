@@ -0,0 +1,80 @@
+package script
+
+import (
+	"fmt"
+	"testing"
+)
+
+const BIP84_ACCOUNT = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+func TestDescriptorChecksum(t *testing.T) {
+	// the example of BIP380
+	if checksum, err := DescriptorChecksum("raw(deadbeef)"); err != nil || checksum != "89f8spxm" {
+		t.Fatalf("expected 89f8spxm, got %s with error %v", checksum, err)
+	}
+	if _, err := DescriptorChecksum("raw(deadbeef)é"); err == nil {
+		t.Fatal("expected an error for a character outside the descriptor charset")
+	}
+}
+
+func TestParseDescriptor(t *testing.T) {
+	descriptor := "wpkh([73c5da0a/84h/0h/0h]" + BIP84_ACCOUNT + "/<0;1>/*)"
+	checksum, err := DescriptorChecksum(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		descriptor string
+		valid      bool
+	}{
+		{"without checksum", descriptor, true},
+		{"with checksum", descriptor + "#" + checksum, true},
+		{"with a wrong checksum", descriptor + "#" + checksum[1:] + checksum[:1], false},
+		{"hardened step", "wpkh(" + BIP84_ACCOUNT + "/0h/*)", false},
+		{"wildcard before the end", "wpkh(" + BIP84_ACCOUNT + "/*/0)", false},
+		{"two multipath steps", "wpkh(" + BIP84_ACCOUNT + "/<0;1>/<0;1>/*)", false},
+		{"bad fingerprint", "wpkh([73c5da/84h/0h/0h]" + BIP84_ACCOUNT + "/0/*)", false},
+		{"multisig", "wsh(multi(1," + BIP84_ACCOUNT + "))", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseDescriptor(test.descriptor, Mainnet); (err == nil) != test.valid {
+				t.Errorf("expected valid to be %t, got error %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestDescriptorAddresses(t *testing.T) {
+	descriptor, err := ParseDescriptor("wpkh([73c5da0a/84h/0h/0h]"+BIP84_ACCOUNT+"/<0;1>/*)", Mainnet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.AddressType != AddressType_P2WPKH || descriptor.Origin != "m/84'/0'/0'" || len(descriptor.Chains) != 2 {
+		t.Fatalf("unexpected descriptor %+v", descriptor)
+	}
+
+	// the first receive and change addresses of BIP84, the key of the receive address is given there
+	expected := []string{
+		"[bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu m/84'/0'/0'/0/0]",
+		"[bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el m/84'/0'/0'/1/0]",
+	}
+	for i, chain := range descriptor.Chains {
+		addresses, err := descriptor.Addresses(chain, 0, 1, Mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint([]string{addresses[0].Address, addresses[0].Path}); got != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], got)
+		}
+		if i == 0 && fmt.Sprintf("%x", addresses[0].PubKey) != "0330d54fd0dd420a6e5f8d3624f5f3482cae350f79d5f0753bf5beef9c2d91af3c" {
+			t.Errorf("unexpected key %x", addresses[0].PubKey)
+		}
+		indexes := fmt.Sprint([]uint32{84 + HARDENED_INDEX, HARDENED_INDEX, HARDENED_INDEX, uint32(i), 0})
+		if fmt.Sprintf("%x", addresses[0].Fingerprint) != "73c5da0a" || fmt.Sprint(addresses[0].Indexes) != indexes {
+			t.Errorf("expected the origin 73c5da0a%s, got %x%v", indexes, addresses[0].Fingerprint, addresses[0].Indexes)
+		}
+	}
+}
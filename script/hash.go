@@ -0,0 +1,32 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Hash160 is RIPEMD160(SHA256(data)), the hash committed to by P2PKH and P2SH outputs
+func Hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+// DoubleSha256 is SHA256(SHA256(data)), used for checksums and transaction ids
+func DoubleSha256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// ScriptHash is the Electrum protocol's key for a scriptPubKey: its SHA256, byte-reversed, in hex
+func ScriptHash(script []byte) string {
+	hash := sha256.Sum256(script)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:])
+}
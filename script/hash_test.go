@@ -0,0 +1,28 @@
+package script
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestHash160(t *testing.T) {
+	if hash := hex.EncodeToString(Hash160(mustDecodeHex(t, GENESIS_PUBKEY))); hash != "62e907b15cbf27d5425399ebf6f0fb50ebb88f18" {
+		t.Errorf("unexpected hash %s", hash)
+	}
+}
+
+func TestDoubleSha256(t *testing.T) {
+	// the id of the genesis coinbase, displayed byte-reversed
+	coinbase := mustDecodeHex(t, "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000")
+	if hash := hex.EncodeToString(DoubleSha256(coinbase)); hash != "3ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a" {
+		t.Errorf("unexpected hash %s", hash)
+	}
+}
+
+func TestScriptHash(t *testing.T) {
+	// the example of the Electrum protocol documentation, the P2PKH script of 1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa
+	script := mustDecodeHex(t, "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac")
+	if hash := ScriptHash(script); hash != "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161" {
+		t.Errorf("unexpected script hash %s", hash)
+	}
+}
@@ -0,0 +1,47 @@
+package script
+
+import (
+	"fmt"
+	"os"
+)
+
+const ENV_BTC_NETWORK = "BTC_NETWORK"
+
+// Network holds the address encoding parameters of a chain
+type Network struct {
+	Name             string
+	PubKeyHashPrefix byte
+	ScriptHashPrefix byte
+	Bech32HRP        string
+}
+
+var (
+	Mainnet = &Network{Name: "mainnet", PubKeyHashPrefix: 0x00, ScriptHashPrefix: 0x05, Bech32HRP: "bc"}
+	Testnet = &Network{Name: "testnet", PubKeyHashPrefix: 0x6f, ScriptHashPrefix: 0xc4, Bech32HRP: "tb"}
+	Signet  = &Network{Name: "signet", PubKeyHashPrefix: 0x6f, ScriptHashPrefix: 0xc4, Bech32HRP: "tb"}
+	Regtest = &Network{Name: "regtest", PubKeyHashPrefix: 0x6f, ScriptHashPrefix: 0xc4, Bech32HRP: "bcrt"}
+)
+
+var networks = map[string]*Network{
+	Mainnet.Name: Mainnet,
+	Testnet.Name: Testnet,
+	Signet.Name:  Signet,
+	Regtest.Name: Regtest,
+	// the names used by bitcoind's -chain option
+	"main": Mainnet,
+	"test": Testnet,
+}
+
+// NetworkFromEnv returns the network named by BTC_NETWORK, mainnet when it's unset. An unknown name is an error
+// rather than a guess, addresses encoded for the wrong chain would be stored
+func NetworkFromEnv() (*Network, error) {
+	name := os.Getenv(ENV_BTC_NETWORK)
+	if name == "" {
+		return Mainnet, nil
+	}
+	network, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s %q, expected mainnet, testnet, signet or regtest", ENV_BTC_NETWORK, name)
+	}
+	return network, nil
+}
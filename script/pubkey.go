@@ -0,0 +1,88 @@
+package script
+
+const (
+	OP_1             = 0x51
+	OP_16            = 0x60
+	OP_CHECKSIG      = 0xac
+	OP_CHECKMULTISIG = 0xae
+
+	COMPRESSED_PUBKEY_SIZE   = 33
+	UNCOMPRESSED_PUBKEY_SIZE = 65
+)
+
+// IsValidPubKey checks the size against the prefix byte, the way bitcoind's solver does
+func IsValidPubKey(pubKey []byte) bool {
+	if len(pubKey) == 0 {
+		return false
+	}
+	switch pubKey[0] {
+	case 0x02, 0x03:
+		return len(pubKey) == COMPRESSED_PUBKEY_SIZE
+	case 0x04, 0x06, 0x07:
+		return len(pubKey) == UNCOMPRESSED_PUBKEY_SIZE
+	}
+	return false
+}
+
+// PubKeys returns the public keys of a P2PK (<pubkey> OP_CHECKSIG) or bare multisig
+// (OP_m <pubkey>... OP_n OP_CHECKMULTISIG) script, nil for any other script
+func PubKeys(script []byte) [][]byte {
+	if pubKey, ok := payToPubKey(script); ok {
+		return [][]byte{pubKey}
+	}
+	if pubKeys, ok := multisig(script); ok {
+		return pubKeys
+	}
+	return nil
+}
+
+func payToPubKey(script []byte) ([]byte, bool) {
+	if len(script) < 2 || script[len(script)-1] != OP_CHECKSIG {
+		return nil, false
+	}
+	size := int(script[0])
+	if size != COMPRESSED_PUBKEY_SIZE && size != UNCOMPRESSED_PUBKEY_SIZE || len(script) != size+2 {
+		return nil, false
+	}
+	pubKey := script[1 : size+1]
+	return pubKey, IsValidPubKey(pubKey)
+}
+
+func multisig(script []byte) ([][]byte, bool) {
+	if len(script) < 3 || script[len(script)-1] != OP_CHECKMULTISIG {
+		return nil, false
+	}
+	required, total := script[0], script[len(script)-2]
+	if required < OP_1 || required > OP_16 || total < OP_1 || total > OP_16 || required > total {
+		return nil, false
+	}
+
+	var pubKeys [][]byte
+	for remaining := script[1 : len(script)-2]; len(remaining) > 0; {
+		data, rest, ok := nextPush(remaining)
+		if !ok || !IsValidPubKey(data) {
+			return nil, false
+		}
+		pubKeys = append(pubKeys, data)
+		remaining = rest
+	}
+	if len(pubKeys) != int(total-OP_1+1) {
+		return nil, false
+	}
+	return pubKeys, true
+}
+
+// PubKeyHashAddress is the P2PKH address spendable by `pubKey`
+func PubKeyHashAddress(pubKey []byte, network *Network) string {
+	return Base58CheckEncode(network.PubKeyHashPrefix, Hash160(pubKey))
+}
+
+// KeyAddresses returns the P2PKH address of every key of a P2PK or bare multisig script,
+// which is how coins without an address of their own are found
+func KeyAddresses(script []byte, network *Network) []string {
+	var addresses []string
+	for _, pubKey := range PubKeys(script) {
+		addresses = append(addresses, PubKeyHashAddress(pubKey, network))
+	}
+	return addresses
+}
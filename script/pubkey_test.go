@@ -0,0 +1,61 @@
+package script
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+const (
+	// the key paid by the coinbase of the genesis block
+	GENESIS_PUBKEY = "04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f"
+	// the generator point, the public key of the private key 1
+	COMPRESSED_G   = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	UNCOMPRESSED_G = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestKeyAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		addresses []string
+	}{
+		{"genesis coinbase", "41" + GENESIS_PUBKEY + "ac", []string{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"}},
+		{"compressed p2pk", "21" + COMPRESSED_G + "ac", []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"}},
+		{"uncompressed p2pk", "41" + UNCOMPRESSED_G + "ac", []string{"1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm"}},
+		{"1-of-2 multisig", "5121" + COMPRESSED_G + "41" + GENESIS_PUBKEY + "52ae", []string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"}},
+		{"p2pkh", "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", nil},
+		{"p2pk with a wrong key size", "21" + UNCOMPRESSED_G[:66] + "ac", nil},
+		{"p2pk with an invalid prefix", "21" + "05" + COMPRESSED_G[2:] + "ac", nil},
+		{"p2pk with trailing data", "21" + COMPRESSED_G + "acac", nil},
+		{"multisig with m above n", "5221" + COMPRESSED_G + "41" + GENESIS_PUBKEY + "51ae", nil},
+		{"multisig with n above the key count", "5121" + COMPRESSED_G + "41" + GENESIS_PUBKEY + "53ae", nil},
+		{"multisig with m of zero", "0021" + COMPRESSED_G + "51ae", nil},
+		{"multisig with an invalid key", "5121" + COMPRESSED_G + "21" + "04" + COMPRESSED_G[2:] + "52ae", nil},
+		{"multisig with a truncated push", "5121" + COMPRESSED_G[:40] + "51ae", nil},
+		{"empty script", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addresses := KeyAddresses(mustDecodeHex(t, test.script), Mainnet)
+			if fmt.Sprint(addresses) != fmt.Sprint(test.addresses) {
+				t.Errorf("expected %v, got %v", test.addresses, addresses)
+			}
+		})
+	}
+}
+
+func TestPubKeyHashAddressOnTestnet(t *testing.T) {
+	if address := PubKeyHashAddress(mustDecodeHex(t, COMPRESSED_G), Testnet); address != "mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r" {
+		t.Errorf("expected mrCDrCybB6J1vRfbwM5hemdJz73FwDBC8r, got %s", address)
+	}
+}
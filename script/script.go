@@ -0,0 +1,69 @@
+package script
+
+const (
+	OP_0         = 0x00
+	OP_PUSHDATA1 = 0x4c
+	OP_PUSHDATA2 = 0x4d
+	OP_PUSHDATA4 = 0x4e
+	OP_RETURN    = 0x6a
+
+	// MAX_SCRIPT_SIZE is the consensus limit above which a script can never be executed
+	MAX_SCRIPT_SIZE = 10000
+)
+
+// IsUnspendable tells whether an output with this scriptPubKey can provably never be spent
+func IsUnspendable(script []byte) bool {
+	return len(script) > MAX_SCRIPT_SIZE || IsNullData(script)
+}
+
+// IsNullData tells whether the script starts with OP_RETURN
+func IsNullData(script []byte) bool {
+	return len(script) > 0 && script[0] == OP_RETURN
+}
+
+// NullDataPayload returns the data pushed after OP_RETURN, concatenated. Scripts which don't consist
+// of data pushes only, e.g. with a truncated push, return everything after OP_RETURN as it is
+func NullDataPayload(script []byte) []byte {
+	if !IsNullData(script) {
+		return nil
+	}
+
+	raw := script[1:]
+	var payload []byte
+	for remaining := raw; len(remaining) > 0; {
+		data, rest, ok := nextPush(remaining)
+		if !ok {
+			return raw
+		}
+		payload = append(payload, data...)
+		remaining = rest
+	}
+	return payload
+}
+
+// nextPush decodes the data push at the start of `script`
+func nextPush(script []byte) (data []byte, rest []byte, ok bool) {
+	opcode := script[0]
+	script = script[1:]
+
+	var size int
+	switch {
+	case opcode == OP_0:
+		return nil, script, true
+	case opcode < OP_PUSHDATA1:
+		size = int(opcode)
+	case opcode == OP_PUSHDATA1 && len(script) >= 1:
+		size, script = int(script[0]), script[1:]
+	case opcode == OP_PUSHDATA2 && len(script) >= 2:
+		size, script = int(script[0])|int(script[1])<<8, script[2:]
+	case opcode == OP_PUSHDATA4 && len(script) >= 4:
+		size, script = int(script[0])|int(script[1])<<8|int(script[2])<<16|int(script[3])<<24, script[4:]
+	default:
+		return nil, nil, false
+	}
+
+	if size < 0 || size > len(script) {
+		return nil, nil, false
+	}
+	return script[:size], script[size:], true
+}
@@ -0,0 +1,229 @@
+package script
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// secp256k1 domain parameters, the curve is y² = x³ + 7 over the field of size P
+var (
+	curveP, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f", 16)
+	curveN, _  = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	curveGx, _ = new(big.Int).SetString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", 16)
+	curveGy, _ = new(big.Int).SetString("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8", 16)
+	curveB     = big.NewInt(7)
+
+	// sqrtExponent is (P+1)/4, P ≡ 3 mod 4 so a square root of v is v^sqrtExponent
+	sqrtExponent = new(big.Int).Rsh(new(big.Int).Add(curveP, big.NewInt(1)), 2)
+)
+
+// point is an affine curve point, nil is the point at infinity
+type point struct {
+	x, y *big.Int
+}
+
+// jacobianPoint represents the affine point (x/z², y/z³), z = 0 is the point at infinity
+type jacobianPoint struct {
+	x, y, z *big.Int
+}
+
+// BASE_WINDOW_BITS is the width of the windows k·G is computed with, one addition per window
+const BASE_WINDOW_BITS = 8
+
+var (
+	baseMultiples     [][]*point // j·2^(8w)·G for the window w and j in [1, 256), at baseMultiples[w][j-1]
+	baseMultiplesOnce sync.Once
+)
+
+// parsePubKey decodes a compressed or uncompressed public key into a curve point
+func parsePubKey(pubKey []byte) (*point, error) {
+	switch {
+	case len(pubKey) == COMPRESSED_PUBKEY_SIZE && (pubKey[0] == 0x02 || pubKey[0] == 0x03):
+		return liftX(new(big.Int).SetBytes(pubKey[1:]), pubKey[0] == 0x03)
+	case len(pubKey) == UNCOMPRESSED_PUBKEY_SIZE && pubKey[0] == 0x04:
+		p := &point{x: new(big.Int).SetBytes(pubKey[1:33]), y: new(big.Int).SetBytes(pubKey[33:])}
+		if !p.onCurve() {
+			return nil, errors.New("public key is not on the curve")
+		}
+		return p, nil
+	}
+	return nil, errors.New("malformed public key")
+}
+
+// liftX returns the point with abscissa `x` and the requested parity of its ordinate
+func liftX(x *big.Int, odd bool) (*point, error) {
+	if x.Cmp(curveP) >= 0 {
+		return nil, errors.New("public key is not on the curve")
+	}
+	y := new(big.Int).Exp(x, big.NewInt(3), curveP)
+	y.Add(y, curveB).Mod(y, curveP)
+	y.Exp(y, sqrtExponent, curveP)
+
+	p := &point{x: new(big.Int).Set(x), y: y}
+	if !p.onCurve() {
+		return nil, errors.New("public key is not on the curve")
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(curveP, y)
+	}
+	return p, nil
+}
+
+func (p *point) onCurve() bool {
+	left := new(big.Int).Mul(p.y, p.y)
+	left.Mod(left, curveP)
+	right := new(big.Int).Exp(p.x, big.NewInt(3), curveP)
+	right.Add(right, curveB).Mod(right, curveP)
+	return left.Cmp(right) == 0
+}
+
+// compressed serializes the point as a 33 bytes public key
+func (p *point) compressed() []byte {
+	pubKey := make([]byte, COMPRESSED_PUBKEY_SIZE)
+	pubKey[0] = 0x02 + byte(p.y.Bit(0))
+	p.x.FillBytes(pubKey[1:])
+	return pubKey
+}
+
+// xOnly serializes the abscissa of the point, the way BIP340 keys are
+func (p *point) xOnly() []byte {
+	return p.x.FillBytes(make([]byte, 32))
+}
+
+// tweakAdd returns p + k·G, or an error when k isn't a valid scalar or the sum is the point at infinity
+func tweakAdd(p *point, k *big.Int) (*point, error) {
+	if k.Cmp(curveN) >= 0 {
+		return nil, errors.New("tweak out of range")
+	}
+	sum := scalarBaseMult(k).addAffine(p).affine()
+	if sum == nil {
+		return nil, errors.New("tweak results in the point at infinity")
+	}
+	return sum, nil
+}
+
+// scalarBaseMult computes k·G as the sum of the precomputed multiples of G for each byte of k
+func scalarBaseMult(k *big.Int) *jacobianPoint {
+	baseMultiplesOnce.Do(func() {
+		base := &point{x: curveGx, y: curveGy}
+		for w := 0; w < 256/BASE_WINDOW_BITS; w++ {
+			multiples := []*point{base}
+			current := base.jacobian()
+			for j := 2; j < 1<<BASE_WINDOW_BITS; j++ {
+				current = current.addAffine(base)
+				multiples = append(multiples, current.affine())
+			}
+			baseMultiples = append(baseMultiples, multiples)
+			base = current.addAffine(base).affine()
+		}
+	})
+
+	result := infinity()
+	for w, b := range k.FillBytes(make([]byte, 32)) {
+		if b != 0 {
+			result = result.addAffine(baseMultiples[31-w][b-1])
+		}
+	}
+	return result
+}
+
+func infinity() *jacobianPoint {
+	return &jacobianPoint{x: big.NewInt(1), y: big.NewInt(1), z: new(big.Int)}
+}
+
+func (p *point) jacobian() *jacobianPoint {
+	return &jacobianPoint{x: new(big.Int).Set(p.x), y: new(big.Int).Set(p.y), z: big.NewInt(1)}
+}
+
+func (p *jacobianPoint) affine() *point {
+	if p.z.Sign() == 0 {
+		return nil
+	}
+	zInv := new(big.Int).ModInverse(p.z, curveP)
+	zInv2 := new(big.Int).Mul(zInv, zInv)
+	zInv2.Mod(zInv2, curveP)
+	zInv3 := new(big.Int).Mul(zInv2, zInv)
+	zInv3.Mod(zInv3, curveP)
+
+	x := new(big.Int).Mul(p.x, zInv2)
+	y := new(big.Int).Mul(p.y, zInv3)
+	return &point{x: x.Mod(x, curveP), y: y.Mod(y, curveP)}
+}
+
+// double follows the dbl-2009-l formulas for a = 0
+func (p *jacobianPoint) double() *jacobianPoint {
+	if p.z.Sign() == 0 || p.y.Sign() == 0 {
+		return infinity()
+	}
+	a := mulMod(p.x, p.x)
+	b := mulMod(p.y, p.y)
+	c := mulMod(b, b)
+	d := new(big.Int).Add(p.x, b)
+	d = mulMod(d, d)
+	d.Sub(d, a).Sub(d, c).Lsh(d, 1).Mod(d, curveP)
+	e := new(big.Int).Mul(a, big.NewInt(3))
+	f := mulMod(e, e)
+
+	x := new(big.Int).Sub(f, new(big.Int).Lsh(d, 1))
+	x.Mod(x, curveP)
+	y := new(big.Int).Sub(d, x)
+	y = mulMod(e, y)
+	y.Sub(y, new(big.Int).Lsh(c, 3)).Mod(y, curveP)
+	z := mulMod(p.y, p.z)
+	z.Lsh(z, 1).Mod(z, curveP)
+	return &jacobianPoint{x: x, y: y, z: z}
+}
+
+// addAffine adds a point whose z is 1, following the madd-2007-bl formulas
+func (p *jacobianPoint) addAffine(q *point) *jacobianPoint {
+	if p.z.Sign() == 0 {
+		return q.jacobian()
+	}
+	z1z1 := mulMod(p.z, p.z)
+	u2 := mulMod(q.x, z1z1)
+	s2 := mulMod(mulMod(q.y, p.z), z1z1)
+	h := new(big.Int).Sub(u2, p.x)
+	h.Mod(h, curveP)
+	r := new(big.Int).Sub(s2, p.y)
+	r.Lsh(r, 1).Mod(r, curveP)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return p.double()
+		}
+		return infinity()
+	}
+
+	hh := mulMod(h, h)
+	i := new(big.Int).Lsh(hh, 2)
+	j := mulMod(h, i)
+	v := mulMod(p.x, i)
+
+	x := mulMod(r, r)
+	x.Sub(x, j).Sub(x, new(big.Int).Lsh(v, 1)).Mod(x, curveP)
+	y := new(big.Int).Sub(v, x)
+	y = mulMod(r, y)
+	y.Sub(y, new(big.Int).Lsh(mulMod(p.y, j), 1)).Mod(y, curveP)
+	z := new(big.Int).Add(p.z, h)
+	z = mulMod(z, z)
+	z.Sub(z, z1z1).Sub(z, hh).Mod(z, curveP)
+	return &jacobianPoint{x: x, y: y, z: z}
+}
+
+func mulMod(a *big.Int, b *big.Int) *big.Int {
+	product := new(big.Int).Mul(a, b)
+	return product.Mod(product, curveP)
+}
+
+// TaggedHash is the BIP340 hash SHA256(SHA256(tag) || SHA256(tag) || data)
+func TaggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	hasher := sha256.New()
+	hasher.Write(tagHash[:])
+	hasher.Write(tagHash[:])
+	for _, chunk := range data {
+		hasher.Write(chunk)
+	}
+	return hasher.Sum(nil)
+}
@@ -0,0 +1,74 @@
+package script
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestScalarBaseMult(t *testing.T) {
+	minusOne := new(big.Int).Sub(curveN, big.NewInt(1))
+	tests := []struct {
+		name   string
+		k      *big.Int
+		pubKey string
+	}{
+		{"1", big.NewInt(1), COMPRESSED_G},
+		{"2", big.NewInt(2), "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{"3", big.NewInt(3), "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"},
+		{"256", big.NewInt(256), "03" + "8282263212c609d9ea2a6e3e172de238d8c39cabd5ac1ca10646e23fd5f51508"},
+		{"n-1", minusOne, "03" + COMPRESSED_G[2:]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := scalarBaseMult(test.k).affine()
+			if p == nil || hex.EncodeToString(p.compressed()) != test.pubKey {
+				t.Fatalf("expected %s, got %v", test.pubKey, p)
+			}
+		})
+	}
+
+	if p := scalarBaseMult(new(big.Int)).affine(); p != nil {
+		t.Fatalf("expected the point at infinity, got %x", p.compressed())
+	}
+}
+
+func TestTweakAdd(t *testing.T) {
+	g, err := parsePubKey(mustDecodeHex(t, COMPRESSED_G))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// G + G·1 doubles G
+	sum, err := tweakAdd(g, big.NewInt(1))
+	if err != nil || hex.EncodeToString(sum.compressed()) != "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5" {
+		t.Fatalf("expected 2G, got %v with error %v", sum, err)
+	}
+	if _, err := tweakAdd(g, new(big.Int).Sub(curveN, big.NewInt(1))); err == nil {
+		t.Fatal("expected G - G to be refused")
+	}
+	if _, err := tweakAdd(g, curveN); err == nil {
+		t.Fatal("expected a tweak of n to be refused")
+	}
+}
+
+func TestParsePubKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		pubKey string
+		valid  bool
+	}{
+		{"compressed", COMPRESSED_G, true},
+		{"uncompressed", UNCOMPRESSED_G, true},
+		{"x not on the curve", "02" + "0000000000000000000000000000000000000000000000000000000000000005", false},
+		{"x above p", "02" + "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30", false},
+		{"uncompressed off the curve", UNCOMPRESSED_G[:len(UNCOMPRESSED_G)-2] + "b9", false},
+		{"hybrid", "06" + UNCOMPRESSED_G[2:], false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parsePubKey(mustDecodeHex(t, test.pubKey)); (err == nil) != test.valid {
+				t.Errorf("expected valid to be %t, got error %v", test.valid, err)
+			}
+		})
+	}
+}
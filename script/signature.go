@@ -0,0 +1,54 @@
+package script
+
+// MAX_SIGNATURE_SIZE is the size of the largest standard ECDSA signature: 71 bytes of low-S DER and the sighash byte
+const MAX_SIGNATURE_SIZE = 72
+
+// IsSignature tells whether `data` is a strictly DER-encoded ECDSA signature followed by its sighash byte, per BIP66
+func IsSignature(data []byte) bool {
+	size := len(data)
+	if size < 9 || size > 73 || data[0] != 0x30 || int(data[1]) != size-3 {
+		return false
+	}
+	rSize := int(data[3])
+	if 5+rSize >= size {
+		return false
+	}
+	sSize := int(data[5+rSize])
+	if rSize+sSize+7 != size {
+		return false
+	}
+	return isDERInteger(data[2:4+rSize]) && isDERInteger(data[4+rSize:6+rSize+sSize])
+}
+
+// isDERInteger checks the encoding of a positive integer with its 0x02 tag and length, without superfluous zeroes
+func isDERInteger(integer []byte) bool {
+	if integer[0] != 0x02 || integer[1] == 0 || integer[2]&0x80 != 0 {
+		return false
+	}
+	return integer[1] == 1 || integer[2] != 0 || integer[3]&0x80 != 0
+}
+
+// SignaturePadding is the number of bytes the ECDSA signatures among `pushes` lack to be of the maximum size
+func SignaturePadding(pushes [][]byte) int64 {
+	var padding int64
+	for _, data := range pushes {
+		if IsSignature(data) && len(data) < MAX_SIGNATURE_SIZE {
+			padding += int64(MAX_SIGNATURE_SIZE - len(data))
+		}
+	}
+	return padding
+}
+
+// Pushes returns the data pushed by `script`, nil when it isn't made of data pushes only
+func Pushes(script []byte) [][]byte {
+	var pushes [][]byte
+	for remaining := script; len(remaining) > 0; {
+		data, rest, ok := nextPush(remaining)
+		if !ok {
+			return nil
+		}
+		pushes = append(pushes, data)
+		remaining = rest
+	}
+	return pushes
+}
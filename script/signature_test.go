@@ -0,0 +1,73 @@
+package script
+
+import (
+	"bytes"
+	"testing"
+)
+
+// BLOCK_170_SIGNATURE signs the first transaction between two people, from Satoshi to Hal Finney
+const BLOCK_170_SIGNATURE = "304402204e45e16932b8af514961a1d3a1a25fdf3f4f7732e9d624c6c61548ab5fb8cd410220181522ec8eca07de4860a4acdd12909d831cc56cbbac4622082221a8768d1d0901"
+
+// derSignature builds a signature of `size` bytes with an S of 32 bytes
+func derSignature(size int) []byte {
+	r := bytes.Repeat([]byte{0x11}, size-39)
+	if len(r) == 33 {
+		r[0], r[1] = 0x00, 0x80
+	}
+	signature := []byte{0x30, byte(size - 3), 0x02, byte(len(r))}
+	signature = append(signature, r...)
+	signature = append(signature, 0x02, 32)
+	signature = append(signature, bytes.Repeat([]byte{0x22}, 32)...)
+	return append(signature, 0x01)
+}
+
+func TestIsSignature(t *testing.T) {
+	modified := func(size int, position int, value byte) []byte {
+		signature := derSignature(size)
+		signature[position] = value
+		return signature
+	}
+	tests := []struct {
+		name      string
+		data      []byte
+		signature bool
+	}{
+		{"block 170", mustDecodeHex(t, BLOCK_170_SIGNATURE), true},
+		{"72 bytes", derSignature(72), true},
+		{"70 bytes", derSignature(70), true},
+		{"not a sequence", modified(71, 0, 0x31), false},
+		{"wrong sequence length", modified(71, 1, 0x45), false},
+		{"R not an integer", modified(71, 2, 0x03), false},
+		{"negative R", modified(71, 4, 0x81), false},
+		{"superfluous zero in R", modified(71, 4, 0x00), false},
+		{"S not an integer", modified(71, 36, 0x03), false},
+		{"negative S", modified(71, 38, 0x81), false},
+		{"schnorr", bytes.Repeat([]byte{0x30}, 64), false},
+		{"compressed key", mustDecodeHex(t, COMPRESSED_G), false},
+		{"empty", nil, false},
+	}
+	for _, test := range tests {
+		if IsSignature(test.data) != test.signature {
+			t.Errorf("%s: expected IsSignature to be %t", test.name, test.signature)
+		}
+	}
+}
+
+func TestSignaturePadding(t *testing.T) {
+	key := mustDecodeHex(t, COMPRESSED_G)
+	tests := []struct {
+		name    string
+		script  []byte
+		padding int64
+	}{
+		{"p2pkh", append(append([]byte{71}, mustDecodeHex(t, BLOCK_170_SIGNATURE)...), append([]byte{33}, key...)...), 1},
+		{"multisig", append(append(append([]byte{OP_0, 70}, derSignature(70)...), 72), derSignature(72)...), 2},
+		{"no signature", append([]byte{33}, key...), 0},
+		{"truncated push", []byte{72, 0x30}, 0},
+	}
+	for _, test := range tests {
+		if padding := SignaturePadding(Pushes(test.script)); padding != test.padding {
+			t.Errorf("%s: expected a padding of %d, got %d", test.name, test.padding, padding)
+		}
+	}
+}
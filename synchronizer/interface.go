@@ -5,4 +5,10 @@ import "context"
 //go:generate mockgen -source=./interface.go -destination=mocks/interface_mock.go -package=synchronizer
 type Interface interface {
 	Start(ctx context.Context)
+	Stop()
+	Wait()
+	Status() Status
+	OnTipChanged(fn TipListener)
+	Rewind(ctx context.Context, height int) error
+	VerifyAmounts(ctx context.Context, repair bool) (*AmountReport, error)
 }
@@ -0,0 +1,25 @@
+package synchronizer
+
+import "sync"
+
+// TipListener is called once the indexed tip moved, after a block was applied as well as reverted
+type TipListener func(height int, hash string)
+
+type listeners struct {
+	mu  sync.RWMutex
+	fns []TipListener
+}
+
+func (l *listeners) add(fn TipListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fns = append(l.fns, fn)
+}
+
+func (l *listeners) notify(height int, hash string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, fn := range l.fns {
+		fn(height, hash)
+	}
+}
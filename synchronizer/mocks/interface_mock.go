@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	synchronizer "github.com/ABMatrix/bitcoin-utxo-ms/synchronizer"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,6 +35,32 @@ func (m *MockInterface) EXPECT() *MockInterfaceMockRecorder {
 	return m.recorder
 }
 
+// OnTipChanged mocks base method.
+func (m *MockInterface) OnTipChanged(fn synchronizer.TipListener) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnTipChanged", fn)
+}
+
+// OnTipChanged indicates an expected call of OnTipChanged.
+func (mr *MockInterfaceMockRecorder) OnTipChanged(fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnTipChanged", reflect.TypeOf((*MockInterface)(nil).OnTipChanged), fn)
+}
+
+// Rewind mocks base method.
+func (m *MockInterface) Rewind(ctx context.Context, height int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rewind", ctx, height)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rewind indicates an expected call of Rewind.
+func (mr *MockInterfaceMockRecorder) Rewind(ctx, height interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rewind", reflect.TypeOf((*MockInterface)(nil).Rewind), ctx, height)
+}
+
 // Start mocks base method.
 func (m *MockInterface) Start(ctx context.Context) {
 	m.ctrl.T.Helper()
@@ -45,3 +72,56 @@ func (mr *MockInterfaceMockRecorder) Start(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockInterface)(nil).Start), ctx)
 }
+
+// Status mocks base method.
+func (m *MockInterface) Status() synchronizer.Status {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(synchronizer.Status)
+	return ret0
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockInterfaceMockRecorder) Status() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockInterface)(nil).Status))
+}
+
+// Stop mocks base method.
+func (m *MockInterface) Stop() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Stop")
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockInterfaceMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockInterface)(nil).Stop))
+}
+
+// VerifyAmounts mocks base method.
+func (m *MockInterface) VerifyAmounts(ctx context.Context, repair bool) (*synchronizer.AmountReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAmounts", ctx, repair)
+	ret0, _ := ret[0].(*synchronizer.AmountReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAmounts indicates an expected call of VerifyAmounts.
+func (mr *MockInterfaceMockRecorder) VerifyAmounts(ctx, repair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAmounts", reflect.TypeOf((*MockInterface)(nil).VerifyAmounts), ctx, repair)
+}
+
+// Wait mocks base method.
+func (m *MockInterface) Wait() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Wait")
+}
+
+// Wait indicates an expected call of Wait.
+func (mr *MockInterfaceMockRecorder) Wait() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockInterface)(nil).Wait))
+}
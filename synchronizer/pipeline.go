@@ -0,0 +1,194 @@
+package synchronizer
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+)
+
+const (
+	ENV_PIPELINE_WORKERS       = "PIPELINE_WORKERS"
+	ENV_PIPELINE_LOOKAHEAD     = "PIPELINE_LOOKAHEAD"
+	ENV_PIPELINE_MAX_BUFFER_MB = "PIPELINE_MAX_BUFFER_MB"
+
+	// PIPELINE_CHUNK is how many consecutive blocks a worker fetches at once
+	PIPELINE_CHUNK = 5
+)
+
+// PipelineConfig bounds how far and how much the workers fetch ahead of the block being applied
+type PipelineConfig struct {
+	Workers        int // goroutines fetching blocks concurrently
+	Lookahead      int // blocks fetched ahead of the next block to apply
+	MaxBufferBytes int // serialized size of the blocks fetched but not applied yet
+}
+
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		Workers:        4,
+		Lookahead:      50,
+		MaxBufferBytes: 256 << 20,
+	}
+}
+
+// PipelineConfigFromEnv overrides the defaults with the PIPELINE_* environment variables which are set
+func PipelineConfigFromEnv() PipelineConfig {
+	config := DefaultPipelineConfig()
+	config.Workers = fullnode.IntFromEnv(ENV_PIPELINE_WORKERS, config.Workers)
+	config.Lookahead = fullnode.IntFromEnv(ENV_PIPELINE_LOOKAHEAD, config.Lookahead)
+	config.MaxBufferBytes = fullnode.IntFromEnv(ENV_PIPELINE_MAX_BUFFER_MB, config.MaxBufferBytes>>20) << 20
+	if config.Lookahead < PIPELINE_CHUNK {
+		config.Lookahead = PIPELINE_CHUNK
+	}
+	return config
+}
+
+// chunk is the outcome of fetching PIPELINE_CHUNK blocks starting at some height
+type chunk struct {
+	blocks []*fullnode.Block
+	err    error
+	tip    bool // the chunk reaches the node's tip, there is nothing after it yet
+}
+
+// pipeline fetches blocks ahead with several workers and hands them out strictly in height order
+type pipeline struct {
+	fullnode fullnode.Interface
+	config   PipelineConfig
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+
+	mu            sync.Mutex
+	cond          *sync.Cond
+	start         int            // height the pipeline started at, chunks are aligned on it
+	nextDispatch  int            // first height of the next chunk handed to a worker
+	nextBlock     int            // height of the next block returned by next
+	chunks        map[int]*chunk // fetched chunks keyed by their first height
+	bufferedBytes int
+	stopDispatch  bool // the tip or an error has been reached
+}
+
+func newPipeline(ctx context.Context, f fullnode.Interface, config PipelineConfig, height int) *pipeline {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &pipeline{
+		fullnode:     f,
+		config:       config,
+		ctx:          ctx,
+		cancel:       cancel,
+		start:        height,
+		nextDispatch: height,
+		nextBlock:    height,
+		chunks:       make(map[int]*chunk),
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	// wake up everyone waiting once the pipeline is cancelled
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}()
+
+	for i := 0; i < config.Workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// close stops the workers and drops whatever has been fetched
+func (p *pipeline) close() {
+	p.cancel()
+	p.workers.Wait()
+}
+
+func (p *pipeline) work() {
+	defer p.workers.Done()
+	for {
+		start, ok := p.claim()
+		if !ok {
+			return
+		}
+		blocks, err := p.fullnode.GetBlocksInRange(p.ctx, start, start+PIPELINE_CHUNK-1)
+		p.deliver(start, blocks, err)
+	}
+}
+
+// claim waits until the lookahead and the buffer allow another chunk to be fetched and returns its first height
+func (p *pipeline) claim() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.ctx.Err() != nil || p.stopDispatch {
+			return 0, false
+		}
+		withinLookahead := p.nextDispatch+PIPELINE_CHUNK <= p.nextBlock+p.config.Lookahead
+		withinBuffer := p.bufferedBytes < p.config.MaxBufferBytes
+		if withinLookahead && withinBuffer {
+			start := p.nextDispatch
+			p.nextDispatch += PIPELINE_CHUNK
+			return start, true
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *pipeline) deliver(start int, blocks []*fullnode.Block, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := &chunk{blocks: blocks, err: err}
+	if errors.Is(err, fullnode.ErrNotFound) {
+		c.err = nil
+		c.tip = true
+	} else if err == nil && len(blocks) < PIPELINE_CHUNK {
+		c.tip = true
+	}
+	if c.tip || c.err != nil {
+		p.stopDispatch = true
+	}
+
+	for _, block := range blocks {
+		p.bufferedBytes += block.Size
+	}
+	p.chunks[start] = c
+	p.cond.Broadcast()
+}
+
+// next returns the block following the previous one, fullnode.ErrNotFound once the tip is reached,
+// or the error which stopped the fetching. After an error the pipeline has to be recreated
+func (p *pipeline) next() (*fullnode.Block, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		start := p.start + (p.nextBlock-p.start)/PIPELINE_CHUNK*PIPELINE_CHUNK
+		if c, ok := p.chunks[start]; ok {
+			offset := p.nextBlock - start
+			if offset < len(c.blocks) {
+				block := c.blocks[offset]
+				c.blocks[offset] = nil
+				p.nextBlock++
+				p.bufferedBytes -= block.Size
+				if p.nextBlock-start == PIPELINE_CHUNK {
+					delete(p.chunks, start)
+				}
+				p.cond.Broadcast()
+				return block, nil
+			}
+			if c.err != nil {
+				return nil, c.err
+			}
+			if c.tip {
+				return nil, fullnode.ErrNotFound
+			}
+		}
+		if err := p.ctx.Err(); err != nil {
+			return nil, err
+		}
+		p.cond.Wait()
+	}
+}
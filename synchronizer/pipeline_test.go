@@ -0,0 +1,88 @@
+package synchronizer
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	fmocks "github.com/ABMatrix/bitcoin-utxo-ms/fullnode/mocks"
+	"github.com/golang/mock/gomock"
+)
+
+// fetchRange answers GetBlocksInRange from `chain`, indexed by height, the way bitcoind does at its tip
+func fetchRange(chain []*fullnode.Block, delay time.Duration) func(ctx context.Context, from int, to int) ([]*fullnode.Block, error) {
+	return func(ctx context.Context, from int, to int) ([]*fullnode.Block, error) {
+		if delay > 0 {
+			// workers finish out of order
+			time.Sleep(time.Duration(rand.Int63n(int64(delay))))
+		}
+		if from >= len(chain) {
+			return nil, fullnode.ErrNotFound
+		}
+		if to >= len(chain) {
+			to = len(chain) - 1
+		}
+		// the pipeline clears the blocks it hands out
+		return append([]*fullnode.Block{}, chain[from:to+1]...), nil
+	}
+}
+
+func TestPipelineReturnsBlocksInOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	f := fmocks.NewMockInterface(ctrl)
+
+	const tip = 57
+	var chain []*fullnode.Block
+	for height := 0; height <= tip; height++ {
+		chain = append(chain, &fullnode.Block{Height: height, Size: 1000})
+	}
+	f.EXPECT().GetBlocksInRange(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(fetchRange(chain, 5*time.Millisecond)).AnyTimes()
+
+	const start = 3
+	config := PipelineConfig{Workers: 4, Lookahead: 20, MaxBufferBytes: 10000}
+	blocks := newPipeline(context.Background(), f, config, start)
+	defer blocks.close()
+	for height := start; height <= tip; height++ {
+		block, err := blocks.next()
+		if err != nil {
+			t.Fatalf("unexpected error at height %d: %v", height, err)
+		}
+		if block.Height != height {
+			t.Fatalf("expected block %d, got %d", height, block.Height)
+		}
+	}
+	if _, err := blocks.next(); !errors.Is(err, fullnode.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound past the tip, got %v", err)
+	}
+}
+
+func TestPipelineStopsAtError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	f := fmocks.NewMockInterface(ctrl)
+
+	errNode := errors.New("node error")
+	var chain []*fullnode.Block
+	for height := 0; height < 2*PIPELINE_CHUNK; height++ {
+		chain = append(chain, &fullnode.Block{Height: height})
+	}
+	f.EXPECT().GetBlocksInRange(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, from int, to int) ([]*fullnode.Block, error) {
+		if from >= PIPELINE_CHUNK {
+			return nil, errNode
+		}
+		return fetchRange(chain, 0)(ctx, from, to)
+	}).AnyTimes()
+
+	blocks := newPipeline(context.Background(), f, PipelineConfig{Workers: 2, Lookahead: 20, MaxBufferBytes: 1 << 20}, 0)
+	defer blocks.close()
+	for height := 0; height < PIPELINE_CHUNK; height++ {
+		if block, err := blocks.next(); err != nil || block.Height != height {
+			t.Fatalf("expected block %d, got %v with error %v", height, block, err)
+		}
+	}
+	if _, err := blocks.next(); !errors.Is(err, errNode) {
+		t.Fatalf("expected the node error, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package synchronizer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	fmocks "github.com/ABMatrix/bitcoin-utxo-ms/fullnode/mocks"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	mmocks "github.com/ABMatrix/bitcoin-utxo-ms/mongo/mocks"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
+	"github.com/golang/mock/gomock"
+)
+
+// newChain returns empty blocks from genesis, the hashes of the blocks above `forkHeight` are prefixed by `branch`
+func newChain(tip int, forkHeight int, branch string) []*fullnode.Block {
+	var chain []*fullnode.Block
+	previous := ""
+	for height := 0; height <= tip; height++ {
+		hash := fmt.Sprintf("a%d", height)
+		if height > forkHeight {
+			hash = fmt.Sprintf("%s%d", branch, height)
+		}
+		chain = append(chain, &fullnode.Block{Height: height, Hash: hash, PreviousBlockHash: previous, Confirmations: tip - height + 1})
+		previous = hash
+	}
+	return chain
+}
+
+// appliedChain records the block headers the way the mongo layer does, from ApplyBlock and RevertBlock
+type appliedChain struct {
+	headers  map[int]*mongo.BlockHeader
+	applied  []string
+	reverted []string
+}
+
+func newAppliedChain(m *mmocks.MockInterface, blocks []*fullnode.Block) *appliedChain {
+	c := &appliedChain{headers: map[int]*mongo.BlockHeader{}}
+	for _, block := range blocks {
+		c.headers[block.Height] = &mongo.BlockHeader{Height: block.Height, Hash: block.Hash, PreviousHash: block.PreviousBlockHash}
+	}
+
+	m.EXPECT().GetBlockHeader(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int) (*mongo.BlockHeader, error) {
+		if header, ok := c.headers[height]; ok {
+			return header, nil
+		}
+		return nil, mongo.ErrNotFound
+	}).AnyTimes()
+	m.EXPECT().GetUndoEntry(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int, hash string) (*mongo.UndoEntry, error) {
+		return &mongo.UndoEntry{Height: height, Hash: hash}, nil
+	}).AnyTimes()
+	m.EXPECT().ApplyBlock(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, changes *mongo.BlockChanges, state *mongo.SyncState) error {
+		c.headers[changes.Header.Height] = changes.Header
+		c.applied = append(c.applied, changes.Header.Hash)
+		return nil
+	}).AnyTimes()
+	m.EXPECT().RevertBlock(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int, restoreUtxos []*mongo.UTXO, state *mongo.SyncState) error {
+		c.reverted = append(c.reverted, c.headers[height].Hash)
+		delete(c.headers, height)
+		return nil
+	}).AnyTimes()
+	return c
+}
+
+func expectHashes(t *testing.T, what string, hashes []string, expected ...string) {
+	t.Helper()
+	if fmt.Sprint(hashes) != fmt.Sprint(expected) {
+		t.Fatalf("expected %s blocks %v, got %v", what, expected, hashes)
+	}
+}
+
+func newTestSyncer(m mongo.Interface, f fullnode.Interface) *server {
+	s := New(m, f, nil, script.Mainnet).(*server)
+	s.pipelineConfig = PipelineConfig{Workers: 2, Lookahead: 10, MaxBufferBytes: 1 << 20}
+	return s
+}
+
+func TestSyncAppliesBlocksInOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mmocks.NewMockInterface(ctrl)
+	f := fmocks.NewMockInterface(ctrl)
+
+	node := newChain(12, 12, "")
+	f.EXPECT().GetBlocksInRange(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(fetchRange(node, 0)).AnyTimes()
+	applied := newAppliedChain(m, node[:3])
+
+	height := 3
+	newTestSyncer(m, f).syncBlockStartingAtHeight(context.Background(), &height)
+	if height != 13 {
+		t.Fatalf("expected to stop before height 13, got %d", height)
+	}
+	var expected []string
+	for _, block := range node[3:] {
+		expected = append(expected, block.Hash)
+	}
+	expectHashes(t, "applied", applied.applied, expected...)
+}
+
+func TestFindForkPointOfTwoBlockReorg(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mmocks.NewMockInterface(ctrl)
+	f := fmocks.NewMockInterface(ctrl)
+
+	// the applied chain ends with a2 and a3, the node replaced them with b2, b3 and b4
+	node := newChain(4, 1, "b")
+	newAppliedChain(m, newChain(3, 3, ""))
+	f.EXPECT().GetBlockHash(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int) (string, error) {
+		return node[height].Hash, nil
+	}).AnyTimes()
+
+	s := newTestSyncer(m, f)
+	forkHeight, reorged, err := s.findForkPoint(context.Background(), node[4])
+	if err != nil || !reorged || forkHeight != 1 {
+		t.Fatalf("expected a reorg from height 1, got %d, %t, %v", forkHeight, reorged, err)
+	}
+
+	forkHeight, reorged, err = s.findForkPoint(context.Background(), newChain(4, 4, "")[4])
+	if err != nil || reorged {
+		t.Fatalf("expected a4 to extend the applied chain, got %d, %t, %v", forkHeight, reorged, err)
+	}
+}
+
+func TestSyncRollsBackTwoBlockReorg(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mmocks.NewMockInterface(ctrl)
+	f := fmocks.NewMockInterface(ctrl)
+
+	node := newChain(4, 1, "b")
+	f.EXPECT().GetBlocksInRange(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(fetchRange(node, 0)).AnyTimes()
+	f.EXPECT().GetBlockHash(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, height int) (string, error) {
+		return node[height].Hash, nil
+	}).AnyTimes()
+	applied := newAppliedChain(m, newChain(3, 3, ""))
+
+	s := newTestSyncer(m, f)
+	var tips []string
+	s.OnTipChanged(func(height int, hash string) {
+		tips = append(tips, hash)
+	})
+	height := 4
+	s.syncBlockStartingAtHeight(context.Background(), &height)
+
+	expectHashes(t, "reverted", applied.reverted, "a3", "a2")
+	expectHashes(t, "applied", applied.applied, "b2", "b3", "b4")
+	expectHashes(t, "tip", tips, "a2", "a1", "b2", "b3", "b4")
+	if height != 5 {
+		t.Fatalf("expected to stop before height 5, got %d", height)
+	}
+	f.EXPECT().Health().Return(fullnode.Health{State: fullnode.CircuitClosed})
+	if status := s.Status(); status.IndexedHeight != 4 {
+		t.Fatalf("expected the indexed height to be 4, got %d", status.IndexedHeight)
+	}
+}
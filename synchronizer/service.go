@@ -2,8 +2,11 @@ package synchronizer
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
@@ -11,184 +14,543 @@ import (
 
 	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
 	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+	"github.com/ABMatrix/bitcoin-utxo-ms/notifier"
+	"github.com/ABMatrix/bitcoin-utxo-ms/script"
 )
 
 const (
 	INTERVAL = 2 * time.Minute
+	// NODE_PAUSE is how long syncing pauses after a transient bitcoind failure while the circuit is still closed
+	NODE_PAUSE = 10 * time.Second
+	// VERSION is recorded in the sync checkpoint written after every block
+	VERSION = "0.2.0"
+
+	// ENV_OP_RETURN_INDEX set to "true" keeps OP_RETURN outputs in their own collection
+	ENV_OP_RETURN_INDEX = "OP_RETURN_INDEX"
+	// ENV_ADDRESS_HISTORY_INDEX set to "true" records every address which ever received an output
+	ENV_ADDRESS_HISTORY_INDEX = "ADDRESS_HISTORY_INDEX"
 )
 
 type server struct {
 	mongoServer mongo.Interface
 	fullnode    fullnode.Interface
-	wg          *sync.WaitGroup
-}
+	notifier    notifier.Interface
+	running     *sync.WaitGroup
+	stop        chan struct{}
+	stopOnce    *sync.Once
+	progress    *progress
+	listeners   *listeners
 
-var MapMongoScriptType2BlockScriptType = map[mongo.ScriptType]fullnode.ScriptType{
-	mongo.ScriptType_P2PK:        fullnode.ScriptType_P2PK,
-	mongo.ScriptType_P2PKH:       fullnode.ScriptType_P2PKH,
-	mongo.ScriptType_P2SH:        fullnode.ScriptType_P2SH,
-	mongo.ScriptType_P2WKH:       fullnode.ScriptType_P2WKH,
-	mongo.ScriptType_P2WSH:       fullnode.ScriptType_P2WSH,
-	mongo.ScriptType_NonStandard: fullnode.ScriptType_NonStandard,
+	pipelineConfig PipelineConfig
+	indexOpReturns bool
+	indexAddresses bool
+	network        *script.Network
 }
 
-var MapBlockScriptType2MongoScriptType = map[fullnode.ScriptType]mongo.ScriptType{
-	fullnode.ScriptType_P2PK:        mongo.ScriptType_P2PK,
-	fullnode.ScriptType_P2PKH:       mongo.ScriptType_P2PKH,
-	fullnode.ScriptType_P2SH:        mongo.ScriptType_P2SH,
-	fullnode.ScriptType_P2WKH:       mongo.ScriptType_P2WKH,
-	fullnode.ScriptType_P2WSH:       mongo.ScriptType_P2WSH,
-	fullnode.ScriptType_NonStandard: mongo.ScriptType_NonStandard,
-}
-
-func New(m mongo.Interface, f fullnode.Interface) Interface {
+// New creates the synchronizer, `n` is optional and triggers a sync as soon as a block is announced
+func New(m mongo.Interface, f fullnode.Interface, n notifier.Interface, network *script.Network) Interface {
 	return &server{
 		mongoServer: m,
 		fullnode:    f,
-		wg:          &sync.WaitGroup{},
+		notifier:    n,
+		running:     &sync.WaitGroup{},
+		stop:        make(chan struct{}),
+		stopOnce:    &sync.Once{},
+		progress:    newProgress(),
+		listeners:   &listeners{},
+
+		pipelineConfig: PipelineConfigFromEnv(),
+		indexOpReturns: os.Getenv(ENV_OP_RETURN_INDEX) == "true",
+		indexAddresses: os.Getenv(ENV_ADDRESS_HISTORY_INDEX) == "true",
+		network:        network,
 	}
 }
 
+// Start syncs every block up to the tip and then keeps polling for new blocks, all in the background,
+// until `ctx` is cancelled or Stop is called
 func (s server) Start(ctx context.Context) {
-	maxHeightInDatabase := s.mongoServer.GetMaxHeight(ctx)
-	if maxHeightInDatabase < 0 {
-		log.Println("[error] failed to get max height from database")
+	s.running.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		select {
+		case <-s.stop:
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		defer s.running.Done()
+		s.run(ctx)
+	}()
+}
+
+func (s server) run(ctx context.Context) {
+	height, err := s.resumeHeight(ctx)
+	if err != nil {
+		log.Println("[error] failed to find the height to resume syncing from with error: ", err.Error())
 		return
 	}
+	s.updateNodeHeight(ctx)
 
-	height := maxHeightInDatabase + 1
 	s.syncBlockStartingAtHeight(ctx, &height)
 	log.Println("[debug] all blocks have been synced before ", height)
 
+	// polling keeps going alongside the notifier, it covers announcements missed while the socket was down
+	var announcedBlocks <-chan string
+	if s.notifier != nil {
+		announcedBlocks = s.notifier.Blocks()
+	}
 	ticker := time.NewTicker(INTERVAL)
-	go func(initialHeight int) {
-		currentHeight := initialHeight
-		for {
-			select {
-			case <-ticker.C:
-				height := s.fullnode.GetBestBlockHeight()
-				if height >= currentHeight {
-					s.syncBlockStartingAtHeight(ctx, &currentHeight)
-				}
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[debug] synchronizer stopped before height ", height)
+			return
+		case hash := <-announcedBlocks:
+			log.Println("[debug] block announced: ", hash)
+			s.syncBlockStartingAtHeight(ctx, &height)
+		case <-ticker.C:
+			if bestHeight, ok := s.updateNodeHeight(ctx); ok && bestHeight >= height {
+				s.syncBlockStartingAtHeight(ctx, &height)
 			}
 		}
-	}(height)
+	}
+}
+
+// updateNodeHeight asks bitcoind for its tip and records it in the progress
+func (s server) updateNodeHeight(ctx context.Context) (int, bool) {
+	bestHeight, err := s.fullnode.GetBestBlockHeight(ctx)
+	if err != nil {
+		log.Println("[error] failed to get best block height with error: ", err.Error())
+		return 0, false
+	}
+	s.progress.setNodeHeight(bestHeight)
+	return bestHeight, true
+}
+
+// Status returns the progress of the synchronizer, it's safe to call concurrently
+func (s server) Status() Status {
+	status := s.progress.status()
+	status.Node = s.fullnode.Health()
+	status.Degraded = status.Node.State != fullnode.CircuitClosed
+	return status
+}
+
+// waitForNode pauses syncing until the circuit breaker lets calls through again,
+// it returns false if ctx is done first
+func (s server) waitForNode(ctx context.Context) bool {
+	pause := NODE_PAUSE
+	if health := s.fullnode.Health(); health.State == fullnode.CircuitOpen && health.OpenUntil != nil {
+		pause = time.Until(*health.OpenUntil)
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(pause):
+		return true
+	}
+}
+
+// Stop asks the synchronizer to stop once the block being applied is written
+func (s server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Wait blocks until the synchronizer has stopped
+func (s server) Wait() {
+	s.running.Wait()
+}
+
+// resumeHeight returns the height of the first block which hasn't been applied yet
+func (s server) resumeHeight(ctx context.Context) (int, error) {
+	state, err := s.mongoServer.GetSyncState(ctx)
+	if err == nil {
+		log.Printf("[debug] resuming from checkpoint at height %d (%s) written by version %s\n", state.Height, state.Hash, state.Version)
+		s.progress.setIndexed(state.Height, state.Hash)
+		return state.Height + 1, nil
+	}
+	if !errors.Is(err, mongo.ErrNotFound) {
+		return 0, err
+	}
+
+	// databases synced before the checkpoint existed only have their UTXOs to go by
+	maxHeight, err := s.mongoServer.GetMaxHeight(ctx)
+	if err == nil {
+		log.Println("[warning] no sync checkpoint found, resuming after the highest UTXO at height ", maxHeight)
+		s.progress.setIndexed(maxHeight, "")
+		return maxHeight + 1, nil
+	}
+	if !errors.Is(err, mongo.ErrNotFound) {
+		return 0, fmt.Errorf("failed to get the highest UTXO: %w", err)
+	}
+
+	log.Println("[debug] no sync checkpoint found, syncing from genesis")
+	return 0, nil
 }
 
 // syncBlockStartingAtHeight is a blocking method
-// the returned `height` is the height of the next block which hasn't arrived yet
+// the returned `height` is the height of the next block which hasn't arrived yet.
+// Blocks are fetched ahead by the pipeline's workers and applied here one by one, in height order
 func (s server) syncBlockStartingAtHeight(ctx context.Context, height *int) {
-	curBlock := s.fullnode.GetBlockAtHeight(*height)
-	for curBlock != nil {
-		// sync one block at a time
-		s.wg.Add(1)
-		go s.syncOneBlock(ctx, curBlock)
-		*height++
+	blocks := newPipeline(ctx, s.fullnode, s.pipelineConfig, *height)
+	defer func() {
+		blocks.close()
+	}()
+
+	for {
+		if ctx.Err() != nil {
+			log.Println("[debug] stop syncing before height ", *height)
+			return
+		}
+
+		curBlock, err := blocks.next()
+		if err != nil {
+			// not found means we've caught up with the node
+			if errors.Is(err, fullnode.ErrNotFound) || ctx.Err() != nil {
+				return
+			}
+			if fullnode.IsTransient(err) || errors.Is(err, fullnode.ErrCircuitOpen) {
+				log.Printf("[warning] bitcoind is unavailable, pausing sync at height %d: %s\n", *height, err.Error())
+				blocks.close()
+				if !s.waitForNode(ctx) {
+					return
+				}
+				blocks = newPipeline(ctx, s.fullnode, s.pipelineConfig, *height)
+				continue
+			}
+			log.Printf("[error] failed to get block at height %d with error: %s\n", *height, err.Error())
+			return
+		}
 
-		// getting the next block takes some time and can run simultaneously alongside block syncing
-		if curBlock.NextBlockHash != "" {
-			s.wg.Add(1)
-			go func() {
-				defer s.wg.Done()
-				curBlock = s.fullnode.GetBlock(curBlock.NextBlockHash)
-			}()
-			s.wg.Wait()
+		forkHeight, reorged, err := s.findForkPoint(ctx, curBlock)
+		if err != nil {
+			log.Println("[error] failed to check block against the applied chain with error: ", err.Error())
+			return
+		}
+		if reorged {
+			log.Printf("[warning] chain reorganization detected at height %d, rolling back to height %d\n", curBlock.Height, forkHeight)
+			// whatever has been fetched ahead may belong to the stale branch
+			blocks.close()
+			if err := s.rollback(ctx, curBlock.Height-1, forkHeight); err != nil {
+				log.Println("[error] failed to roll back orphaned blocks with error: ", err.Error())
+				return
+			}
+			*height = forkHeight + 1
+			blocks = newPipeline(ctx, s.fullnode, s.pipelineConfig, *height)
 			continue
 		}
-		s.wg.Add(1)
-		go func() {
-			defer s.wg.Done()
-			curBlock = s.fullnode.GetBlockAtHeight(*height)
-		}()
-		s.wg.Wait()
+
+		// the writes aren't bound to ctx, a shutdown lets the block and its checkpoint be written
+		if err := s.syncOneBlock(context.Background(), curBlock); err != nil {
+			// stop here so the block is retried instead of skipped
+			log.Printf("[error] failed to sync block at height %d with error: %s\n", curBlock.Height, err.Error())
+			return
+		}
+		*height++
 	}
 }
 
-func (s server) syncOneBlock(ctx context.Context, block *fullnode.Block) {
-	defer s.wg.Done()
-
-	if block == nil {
-		return
-	}
+func (s server) syncOneBlock(ctx context.Context, block *fullnode.Block) error {
 	log.Println(fmt.Sprintf("[debug] syncing block at height %d...", block.Height))
 	defer log.Println(fmt.Sprintf("[debug] exiting syncing block at height %d...", block.Height))
 	var deleteKeys []bson.M
+	var spentInputs []*mongo.SpentInput
 	var insertUtxos []*mongo.UTXO
+	// outputs created and spent within this block never enter the UTXO set
+	createdInBlock := make(map[string]bool)
+	spentInBlock := make(map[string]bool)
+	var opReturns []*mongo.OpReturn
+	// addOutputs keeps the spendable outputs of `transaction`, OP_RETURN outputs only go to their own index
+	addOutputs := func(transaction *fullnode.Transaction, coinbase bool) {
+		for _, txout := range transaction.TxOuts {
+			if txout == nil {
+				// we don't give a crap about if the coin is spendable
+				continue
+			}
+			if scriptBytes := mongo.OutputScript(txout); script.IsUnspendable(scriptBytes) {
+				if s.indexOpReturns && script.IsNullData(scriptBytes) {
+					opReturns = append(opReturns, &mongo.OpReturn{
+						TxID:    transaction.Txid,
+						Vout:    txout.Index,
+						Height:  block.Height,
+						Payload: hex.EncodeToString(script.NullDataPayload(scriptBytes)),
+					})
+				}
+				continue
+			}
+			insertUtxos = append(insertUtxos, mongo.NewUTXO(s.network, transaction.Txid, txout, block.Height, coinbase))
+			createdInBlock[outpointKey(transaction.Txid, txout.Index)] = true
+		}
+	}
+
 	log.Println("[debug] looping over transactions in block #", block.Height)
 	for index, transaction := range block.Transactions {
 		if transaction == nil {
 			continue
 		}
+		if block.Height == 0 {
+			// the genesis coinbase can never be spent, bitcoind leaves it out of the UTXO set as well
+			break
+		}
 		if index == 0 {
 			// coinbase transaction
-			for _, txout := range transaction.TxOuts {
-				if txout == nil {
-					// we don't give a crap about if the coin is spendable
-					continue
-				}
-				insertUtxos = append(insertUtxos, &mongo.UTXO{
-					TxID:     transaction.Txid,
-					Vout:     txout.Index,
-					Height:   block.Height,
-					Coinbase: true, // the only difference lies here
-					Amount:   int64(txout.Value * 1e8),
-					Size:     0,
-					Script:   txout.Script.Hex,
-					Type:     MapBlockScriptType2MongoScriptType[txout.Script.Type],
-					Address:  txout.Script.Address,
-				})
-			}
+			addOutputs(transaction, true)
+			continue
 		}
 
 		for _, txin := range transaction.TxIns {
 			if txin == nil {
 				continue
 			}
-			deleteKeys = append(deleteKeys, bson.M{mongo.KEY_TXID: txin.Txid, mongo.KEY_VOUT: txin.Vout})
-		}
-		for _, txout := range transaction.TxOuts {
-			if txout == nil {
-				// we don't give a crap about if the coin is spendable
+			if key := outpointKey(txin.Txid, int(txin.Vout)); createdInBlock[key] {
+				spentInBlock[key] = true
 				continue
 			}
-			insertUtxos = append(insertUtxos, &mongo.UTXO{
-				TxID:     transaction.Txid,
-				Vout:     txout.Index,
-				Height:   block.Height,
-				Coinbase: false,
-				Amount:   int64(txout.Value * 1e8),
-				Size:     0,
-				Script:   txout.Script.Hex,
-				Type:     MapBlockScriptType2MongoScriptType[txout.Script.Type],
-				Address:  txout.Script.Address,
-			})
+			deleteKeys = append(deleteKeys, bson.M{mongo.KEY_TXID: txin.Txid, mongo.KEY_VOUT: txin.Vout})
+			spentInputs = append(spentInputs, &mongo.SpentInput{TxID: txin.Txid, Vout: int(txin.Vout), Size: inputSize(txin)})
 		}
+		addOutputs(transaction, false)
 	}
 	log.Println("[debug] finished looping over transactions in block #", block.Height)
-	wgInside := &sync.WaitGroup{}
-	wgInside.Add(1)
-	go func() {
-		defer wgInside.Done()
 
-		log.Println("[debug] started deleting spent UTXOs for block #", block.Height)
-		if err := s.mongoServer.DeleteMany(ctx, deleteKeys); err != nil {
-			log.Println("[error] failed to delete many with error: ", err.Error())
+	var usedAddresses []string
+	if s.indexAddresses {
+		// outputs spent within the block used their address all the same
+		usedAddresses = addressesOf(insertUtxos)
+	}
+	if len(spentInBlock) > 0 {
+		unspentUtxos := insertUtxos[:0]
+		for _, utxo := range insertUtxos {
+			if !spentInBlock[outpointKey(utxo.TxID, utxo.Vout)] {
+				unspentUtxos = append(unspentUtxos, utxo)
+			}
 		}
-		log.Println("[debug] finished deleting spent UTXOs for block #", block.Height)
-	}()
+		insertUtxos = unspentUtxos
+	}
 
-	wgInside.Add(1)
-	go func() {
-		defer wgInside.Done()
+	changes := &mongo.BlockChanges{
+		Header: &mongo.BlockHeader{
+			Height:       block.Height,
+			Hash:         block.Hash,
+			PreviousHash: block.PreviousBlockHash,
+		},
+		DeleteKeys:    deleteKeys,
+		InsertUtxos:   insertUtxos,
+		OpReturns:     opReturns,
+		SpentInputs:   spentInputs,
+		UsedAddresses: usedAddresses,
+	}
+	if err := s.mongoServer.ApplyBlock(ctx, changes, newSyncState(block.Height, block.Hash)); err != nil {
+		return err
+	}
+	s.progress.blockApplied(block.Height, block.Hash)
+	s.listeners.notify(block.Height, block.Hash)
+	s.progress.setNodeHeight(block.Height + block.Confirmations - 1)
+
+	log.Println(fmt.Sprintf("[debug] successfully finished syncing block at height %d...", block.Height))
+	return nil
+}
 
-		log.Println("[debug] started adding new UTXOs for block #", block.Height)
-		if err := s.mongoServer.InsertMany(ctx, insertUtxos); err != nil {
-			log.Println("[error] failed to insert many with error: ", err.Error())
+// addressesOf returns the distinct addresses `utxos` pay to, including every key of bare multisig outputs
+func addressesOf(utxos []*mongo.UTXO) []string {
+	seen := map[string]bool{}
+	var addresses []string
+	for _, utxo := range utxos {
+		for _, address := range append([]string{utxo.Address}, utxo.Addresses...) {
+			if address != "" && !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
 		}
-		log.Println("[debug] finished adding new UTXOs for block #", block.Height)
-	}()
+	}
+	return addresses
+}
 
-	wgInside.Wait()
+// inputSize is the vsize of `txin` as serialized in its transaction, with its ECDSA signatures counted at their
+// maximum size since their size varies between spends of the same script
+func inputSize(txin *fullnode.TxIn) int64 {
+	scriptSigSize := int64(0)
+	if txin.ScriptSig != nil {
+		scriptSig, _ := hex.DecodeString(txin.ScriptSig.Hex)
+		scriptSigSize = int64(len(scriptSig)) + script.SignaturePadding(script.Pushes(scriptSig))
+	}
+	weight := (32 + 4 + varIntSize(scriptSigSize) + scriptSigSize + 4) * mongo.WITNESS_SCALE_FACTOR
+	if len(txin.TxinWitness) > 0 {
+		weight += varIntSize(int64(len(txin.TxinWitness)))
+		for _, item := range txin.TxinWitness {
+			data, _ := hex.DecodeString(item)
+			itemSize := int64(len(data)) + script.SignaturePadding([][]byte{data})
+			weight += varIntSize(itemSize) + itemSize
+		}
+	}
+	return (weight + mongo.WITNESS_SCALE_FACTOR - 1) / mongo.WITNESS_SCALE_FACTOR
+}
 
-	log.Println(fmt.Sprintf("[debug] successfully finished syncing block at height %d...", block.Height))
+// varIntSize is the size of bitcoin's CompactSize encoding of `n`
+func varIntSize(n int64) int64 {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= 0xffff:
+		return 3
+	case n <= 0xffffffff:
+		return 5
+	}
+	return 9
+}
+
+func outpointKey(txid string, vout int) string {
+	return fmt.Sprintf("%s:%d", txid, vout)
+}
+
+func newSyncState(height int, hash string) *mongo.SyncState {
+	return &mongo.SyncState{
+		Height:    height,
+		Hash:      hash,
+		Timestamp: time.Now().UTC(),
+		Version:   VERSION,
+	}
+}
+
+// findForkPoint checks that `block` extends the last applied block. On a mismatch it walks back
+// until the applied chain agrees with the node again and returns the height of that last common block.
+func (s server) findForkPoint(ctx context.Context, block *fullnode.Block) (int, bool, error) {
+	if block.Height == 0 {
+		return 0, false, nil
+	}
+
+	parent, err := s.mongoServer.GetBlockHeader(ctx, block.Height-1)
+	if errors.Is(err, mongo.ErrNotFound) {
+		// nothing recorded for the parent, e.g. blocks synced before hashes were stored
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if parent.Hash == block.PreviousBlockHash {
+		return 0, false, nil
+	}
+
+	for height := block.Height - 2; height >= 0; height-- {
+		applied, err := s.mongoServer.GetBlockHeader(ctx, height)
+		if errors.Is(err, mongo.ErrNotFound) {
+			// blocks without a recorded hash cannot be compared, consider them part of the active chain
+			return height, true, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		nodeHash, err := s.fullnode.GetBlockHash(ctx, height)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to get block hash at height %d: %w", height, err)
+		}
+		if nodeHash == applied.Hash {
+			return height, true, nil
+		}
+	}
+	return -1, true, nil
+}
+
+// OnTipChanged registers `fn` to be called from the sync loop whenever the indexed tip moves, it must not block
+func (s server) OnTipChanged(fn TipListener) {
+	s.listeners.add(fn)
+}
+
+// Rewind reverts every applied block above `height`
+func (s server) Rewind(ctx context.Context, height int) error {
+	state, err := s.mongoServer.GetSyncState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sync checkpoint: %w", err)
+	}
+	if height >= state.Height {
+		return fmt.Errorf("nothing to rewind, the checkpoint is at height %d", state.Height)
+	}
+	return s.rollback(ctx, state.Height, height)
+}
+
+// rollback undoes the applied blocks from `tipHeight` down to `forkHeight` (exclusive), newest first
+func (s server) rollback(ctx context.Context, tipHeight int, forkHeight int) error {
+	for height := tipHeight; height > forkHeight; height-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := s.mongoServer.GetBlockHeader(ctx, height)
+		if err != nil {
+			return fmt.Errorf("failed to get applied block at height %d: %w", height, err)
+		}
+		// like syncOneBlock, a block being reverted is always written to the end
+		if err := s.undoBlock(context.Background(), header, forkHeight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoBlock deletes the UTXOs created by the block, restores the ones it spent and moves the checkpoint to its parent
+func (s server) undoBlock(ctx context.Context, header *mongo.BlockHeader, forkHeight int) error {
+	log.Printf("[debug] undoing block %s at height %d...\n", header.Hash, header.Height)
+
+	spentUtxos, err := s.getSpentUtxos(ctx, header)
+	if err != nil {
+		return err
+	}
+
+	var restoreUtxos []*mongo.UTXO
+	for _, utxo := range spentUtxos {
+		// coins created by the other orphaned blocks are removed along with them
+		if utxo.Height > forkHeight {
+			continue
+		}
+		restoreUtxos = append(restoreUtxos, utxo)
+	}
+
+	if err := s.mongoServer.RevertBlock(ctx, header.Height, restoreUtxos, newSyncState(header.Height-1, header.PreviousHash)); err != nil {
+		return fmt.Errorf("failed to revert block at height %d: %w", header.Height, err)
+	}
+	s.progress.setIndexed(header.Height-1, header.PreviousHash)
+	s.listeners.notify(header.Height-1, header.PreviousHash)
+	return nil
+}
+
+// getSpentUtxos returns the UTXOs spent by a block, from the undo journal while it still holds the block
+// and from bitcoind otherwise
+func (s server) getSpentUtxos(ctx context.Context, header *mongo.BlockHeader) ([]*mongo.UTXO, error) {
+	entry, err := s.mongoServer.GetUndoEntry(ctx, header.Height, header.Hash)
+	if err == nil {
+		return entry.Spent, nil
+	}
+	if !errors.Is(err, mongo.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get undo entry at height %d: %w", header.Height, err)
+	}
+
+	// bitcoind keeps orphaned blocks around, so they can still be fetched by hash
+	block, err := s.fullnode.GetBlockWithPrevouts(ctx, header.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %w", header.Hash, err)
+	}
+
+	var spentUtxos []*mongo.UTXO
+	for _, transaction := range block.Transactions {
+		if transaction == nil {
+			continue
+		}
+		for _, txin := range transaction.TxIns {
+			if txin == nil || txin.Coinbase != "" {
+				continue
+			}
+			if txin.Prevout == nil || txin.Prevout.Script == nil {
+				return nil, fmt.Errorf("missing prevout for %s:%d, rolling back requires bitcoind 23.0 or later", txin.Txid, txin.Vout)
+			}
+			spent := &fullnode.TxOut{Value: txin.Prevout.Value, Index: int(txin.Vout), Script: txin.Prevout.Script}
+			spentUtxos = append(spentUtxos, mongo.NewUTXO(s.network, txin.Txid, spent, txin.Prevout.Height, txin.Prevout.Generated))
+		}
+	}
+	return spentUtxos, nil
 }
@@ -0,0 +1,48 @@
+package synchronizer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+)
+
+const COMPRESSED_KEY = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+// signatureHex builds a DER signature of `size` bytes, with its sighash byte and an R of 32 bytes
+func signatureHex(size int) string {
+	return fmt.Sprintf("30%02x0220%s02%02x%s01", size-3, strings.Repeat("11", 32), size-39, strings.Repeat("22", size-39))
+}
+
+func TestInputSizeCountsSignaturesAtTheirMaximumSize(t *testing.T) {
+	// a 2-of-3 multisig witness script
+	witnessScript := "52" + strings.Repeat("21"+COMPRESSED_KEY, 3) + "53ae"
+	p2pkh := func(signatureSize int) *fullnode.TxIn {
+		return &fullnode.TxIn{ScriptSig: &fullnode.Script{Hex: fmt.Sprintf("%02x%s21%s", signatureSize, signatureHex(signatureSize), COMPRESSED_KEY)}}
+	}
+	p2wsh := func(signatureSizes ...int) *fullnode.TxIn {
+		witness := []string{""}
+		for _, size := range signatureSizes {
+			witness = append(witness, signatureHex(size))
+		}
+		return &fullnode.TxIn{ScriptSig: &fullnode.Script{}, TxinWitness: append(witness, witnessScript)}
+	}
+	tests := []struct {
+		name string
+		txin *fullnode.TxIn
+		size int64
+	}{
+		{"p2pkh with a 72 bytes signature", p2pkh(72), mongo.ScriptType_P2PKH.EstimatedInputSize()},
+		{"p2pkh with a 71 bytes signature", p2pkh(71), mongo.ScriptType_P2PKH.EstimatedInputSize()},
+		{"2-of-3 p2wsh with 72 bytes signatures", p2wsh(72, 72), mongo.ScriptType_P2WSH.EstimatedInputSize()},
+		{"2-of-3 p2wsh with shorter signatures", p2wsh(70, 71), mongo.ScriptType_P2WSH.EstimatedInputSize()},
+		{"1-of-3 p2wsh", p2wsh(71), 87},
+	}
+	for _, test := range tests {
+		if size := inputSize(test.txin); size != test.size {
+			t.Errorf("%s: expected %d vbytes, got %d", test.name, test.size, size)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package synchronizer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+)
+
+// RATE_WINDOW is how many of the latest applied blocks the sync rate is computed over
+const RATE_WINDOW = 100
+
+// Status is a snapshot of the synchronizer's progress
+type Status struct {
+	IndexedHeight   int     `json:"indexed_height"`
+	IndexedHash     string  `json:"indexed_hash"`
+	NodeHeight      int     `json:"node_height"`
+	Syncing         bool    `json:"syncing"`
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+	ETASeconds      int64   `json:"eta_seconds"`
+	// Degraded is set while bitcoind is failing and syncing is paused
+	Degraded bool            `json:"degraded"`
+	Node     fullnode.Health `json:"node"`
+}
+
+// progress is shared by the sync loop and the readers of Status
+type progress struct {
+	mu            sync.RWMutex
+	indexedHeight int
+	indexedHash   string
+	nodeHeight    int
+	appliedAt     []time.Time
+}
+
+func newProgress() *progress {
+	return &progress{indexedHeight: -1, nodeHeight: -1}
+}
+
+func (p *progress) setIndexed(height int, hash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.indexedHeight = height
+	p.indexedHash = hash
+	if height > p.nodeHeight {
+		p.nodeHeight = height
+	}
+}
+
+func (p *progress) blockApplied(height int, hash string) {
+	p.mu.Lock()
+	p.appliedAt = append(p.appliedAt, time.Now())
+	if len(p.appliedAt) > RATE_WINDOW {
+		p.appliedAt = p.appliedAt[len(p.appliedAt)-RATE_WINDOW:]
+	}
+	p.mu.Unlock()
+
+	p.setIndexed(height, hash)
+}
+
+func (p *progress) setNodeHeight(height int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if height > p.nodeHeight {
+		p.nodeHeight = height
+	}
+}
+
+func (p *progress) status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := Status{
+		IndexedHeight: p.indexedHeight,
+		IndexedHash:   p.indexedHash,
+		NodeHeight:    p.nodeHeight,
+		Syncing:       p.indexedHeight < p.nodeHeight,
+	}
+	if len(p.appliedAt) > 1 {
+		elapsed := p.appliedAt[len(p.appliedAt)-1].Sub(p.appliedAt[0]).Seconds()
+		if elapsed > 0 {
+			status.BlocksPerSecond = float64(len(p.appliedAt)-1) / elapsed
+		}
+	}
+	if status.Syncing && status.BlocksPerSecond > 0 {
+		status.ETASeconds = int64(float64(status.NodeHeight-status.IndexedHeight) / status.BlocksPerSecond)
+	}
+	return status
+}
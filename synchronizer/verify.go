@@ -0,0 +1,75 @@
+package synchronizer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/fullnode"
+	"github.com/ABMatrix/bitcoin-utxo-ms/mongo"
+)
+
+const (
+	// VERIFY_BATCH_SIZE is how many stored UTXOs are looked up in one gettxout batch
+	VERIFY_BATCH_SIZE = 200
+	// VERIFY_LOG_EVERY is how often progress is logged, in checked UTXOs
+	VERIFY_LOG_EVERY = 100000
+)
+
+// AmountReport summarizes a run of VerifyAmounts
+type AmountReport struct {
+	Checked    int `json:"checked"`
+	Mismatched int `json:"mismatched"`
+	Repaired   int `json:"repaired"`
+	// Missing counts UTXOs the node doesn't have, usually spent after they were indexed
+	Missing int `json:"missing"`
+}
+
+// VerifyAmounts compares the amount of every stored UTXO with the node's UTXO set and, with `repair`,
+// overwrites the amounts which disagree. Databases written before amounts were parsed exactly
+// hold values which are 1 satoshi short
+func (s server) VerifyAmounts(ctx context.Context, repair bool) (*AmountReport, error) {
+	report := &AmountReport{}
+	err := s.mongoServer.ScanUtxos(ctx, VERIFY_BATCH_SIZE, func(utxos []*mongo.UTXO) error {
+		outpoints := make([]*fullnode.Outpoint, 0, len(utxos))
+		for _, utxo := range utxos {
+			outpoints = append(outpoints, &fullnode.Outpoint{Txid: utxo.TxID, Vout: utxo.Vout})
+		}
+
+		txOuts, err := s.fullnode.GetTxOuts(ctx, outpoints)
+		if err != nil {
+			return fmt.Errorf("failed to get outputs from node: %w", err)
+		}
+
+		var fixes []*mongo.UTXO
+		for index, utxo := range utxos {
+			report.Checked++
+			if report.Checked%VERIFY_LOG_EVERY == 0 {
+				log.Printf("[debug] checked %d UTXOs, %d mismatched so far\n", report.Checked, report.Mismatched)
+			}
+
+			txOut := txOuts[index]
+			if txOut == nil {
+				report.Missing++
+				continue
+			}
+			if int64(txOut.Value) == utxo.Amount {
+				continue
+			}
+
+			report.Mismatched++
+			log.Printf("[warning] amount of %s:%d is %d, the node reports %d\n", utxo.TxID, utxo.Vout, utxo.Amount, int64(txOut.Value))
+			fixes = append(fixes, &mongo.UTXO{TxID: utxo.TxID, Vout: utxo.Vout, Amount: int64(txOut.Value)})
+		}
+
+		if !repair || len(fixes) == 0 {
+			return nil
+		}
+		if err := s.mongoServer.UpdateAmounts(ctx, fixes); err != nil {
+			return fmt.Errorf("failed to repair amounts: %w", err)
+		}
+		report.Repaired += len(fixes)
+		return nil
+	})
+	return report, err
+}
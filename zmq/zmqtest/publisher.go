@@ -0,0 +1,170 @@
+// Package zmqtest provides a fake ZMTP 3.0 publisher for tests of the packages subscribing to bitcoind
+package zmqtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/zmq"
+)
+
+const ACCEPT_TIMEOUT = 5 * time.Second
+
+// Publisher is a fake PUB socket with the NULL mechanism, it completes the handshake with every subscriber and
+// hands the connections over to the test
+type Publisher struct {
+	listener net.Listener
+	conns    chan *Conn
+}
+
+// Conn is a subscriber connected to a Publisher
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewPublisher listens on a random local port until the test ends
+func NewPublisher(t testing.TB) *Publisher {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Publisher{listener: listener, conns: make(chan *Conn, 4)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.handshake(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return p
+}
+
+func (p *Publisher) Endpoint() string {
+	return "tcp://" + p.listener.Addr().String()
+}
+
+// Accept returns the next subscriber which completed the handshake, its subscriptions are left to read
+func (p *Publisher) Accept(t testing.TB) *Conn {
+	t.Helper()
+	select {
+	case c := <-p.conns:
+		return c
+	case <-time.After(ACCEPT_TIMEOUT):
+		t.Fatal("no subscriber connected")
+		return nil
+	}
+}
+
+func (p *Publisher) handshake(conn net.Conn) {
+	c := &Conn{conn: conn, reader: bufio.NewReader(conn)}
+	if _, err := io.ReadFull(c.reader, make([]byte, zmq.GREETING_SIZE)); err != nil {
+		conn.Close()
+		return
+	}
+	greeting := make([]byte, zmq.GREETING_SIZE)
+	greeting[0], greeting[9], greeting[10] = 0xff, 0x7f, 3
+	copy(greeting[12:32], zmq.MECHANISM_NULL)
+	conn.Write(greeting)
+
+	flags, body, err := c.ReadFrame()
+	if err != nil || flags&zmq.FLAG_COMMAND == 0 || !bytes.Contains(body, []byte(zmq.COMMAND_READY)) {
+		conn.Close()
+		return
+	}
+	c.WriteFrame(zmq.FLAG_COMMAND, readyCommand("PUB"))
+	p.conns <- c
+}
+
+func readyCommand(socketType string) []byte {
+	body := &bytes.Buffer{}
+	body.WriteByte(byte(len(zmq.COMMAND_READY)))
+	body.WriteString(zmq.COMMAND_READY)
+	body.WriteByte(byte(len(zmq.PROPERTY_SOCKET_TYPE)))
+	body.WriteString(zmq.PROPERTY_SOCKET_TYPE)
+	binary.Write(body, binary.BigEndian, uint32(len(socketType)))
+	body.WriteString(socketType)
+	return body.Bytes()
+}
+
+// Subscriptions reads the next `count` subscription messages and returns their topics
+func (c *Conn) Subscriptions(count int) ([]string, error) {
+	var topics []string
+	for len(topics) < count {
+		_, body, err := c.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 || body[0] != 1 {
+			return nil, errors.New("expected a subscription")
+		}
+		topics = append(topics, string(body[1:]))
+	}
+	return topics, nil
+}
+
+// Send writes a multipart message, e.g. topic, body and sequence number the way bitcoind does
+func (c *Conn) Send(parts ...[]byte) error {
+	for index, part := range parts {
+		flags := byte(0)
+		if index < len(parts)-1 {
+			flags = zmq.FLAG_MORE
+		}
+		if err := c.WriteFrame(flags, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) WriteFrame(flags byte, body []byte) error {
+	header := []byte{flags, byte(len(body))}
+	if len(body) > 255 {
+		header = []byte{flags | zmq.FLAG_LONG, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	}
+	_, err := c.conn.Write(append(header, body...))
+	return err
+}
+
+func (c *Conn) ReadFrame() (byte, []byte, error) {
+	flags, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmq.FLAG_LONG != 0 {
+		var long [8]byte
+		if _, err := io.ReadFull(c.reader, long[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(long[:])
+	} else {
+		short, err := c.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > zmq.MAX_FRAME_SIZE {
+		return 0, nil, errors.New("frame too large")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
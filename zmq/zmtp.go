@@ -0,0 +1,194 @@
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// ZMTP 3.0 (https://rfc.zeromq.org/spec/23/), only what a SUB socket with the NULL mechanism needs
+const (
+	GREETING_SIZE  = 64
+	MECHANISM_NULL = "NULL"
+
+	FLAG_MORE    = 0x01
+	FLAG_LONG    = 0x02
+	FLAG_COMMAND = 0x04
+
+	COMMAND_READY        = "READY"
+	PROPERTY_SOCKET_TYPE = "Socket-Type"
+	SOCKET_TYPE_SUB      = "SUB"
+
+	// MAX_FRAME_SIZE protects against a corrupted length prefix, bitcoind's largest message is a raw block
+	MAX_FRAME_SIZE = 64 << 20
+
+	DIAL_TIMEOUT = 10 * time.Second
+)
+
+// Subscriber is a SUB socket connected to a single publisher, e.g. bitcoind's -zmqpubhashblock endpoint
+type Subscriber struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Dial connects to `endpoint` (tcp://host:port), performs the handshake and subscribes to `topics`
+func Dial(ctx context.Context, endpoint string, topics ...string) (*Subscriber, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported zmq transport %q", u.Scheme)
+	}
+
+	dialer := &net.Dialer{Timeout: DIAL_TIMEOUT}
+	conn, err := dialer.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscriber{conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+	if err := s.handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("zmq handshake failed: %w", err)
+	}
+	for _, topic := range topics {
+		// ZMTP 3.0 subscriptions are plain messages starting with 0x01
+		if err := s.writeFrame(0, append([]byte{1}, topic...)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return s, nil
+}
+
+func (s *Subscriber) handshake() error {
+	greeting := make([]byte, GREETING_SIZE)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3 // version 3.0
+	greeting[11] = 0
+	copy(greeting[12:32], MECHANISM_NULL)
+	if _, err := s.conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peer := make([]byte, GREETING_SIZE)
+	if _, err := io.ReadFull(s.reader, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return errors.New("invalid greeting signature")
+	}
+	if peer[10] < 3 {
+		return fmt.Errorf("unsupported ZMTP version %d.%d", peer[10], peer[11])
+	}
+	if mechanism := string(bytes.TrimRight(peer[12:32], "\x00")); mechanism != MECHANISM_NULL {
+		return fmt.Errorf("unsupported security mechanism %q", mechanism)
+	}
+
+	if err := s.writeFrame(FLAG_COMMAND, readyCommand(SOCKET_TYPE_SUB)); err != nil {
+		return err
+	}
+	flags, body, err := s.readFrame()
+	if err != nil {
+		return err
+	}
+	if flags&FLAG_COMMAND == 0 || len(body) < 1+len(COMMAND_READY) || string(body[1:1+len(COMMAND_READY)]) != COMMAND_READY {
+		return errors.New("expected a READY command")
+	}
+	return nil
+}
+
+func readyCommand(socketType string) []byte {
+	body := &bytes.Buffer{}
+	body.WriteByte(byte(len(COMMAND_READY)))
+	body.WriteString(COMMAND_READY)
+	body.WriteByte(byte(len(PROPERTY_SOCKET_TYPE)))
+	body.WriteString(PROPERTY_SOCKET_TYPE)
+	binary.Write(body, binary.BigEndian, uint32(len(socketType)))
+	body.WriteString(socketType)
+	return body.Bytes()
+}
+
+func (s *Subscriber) writeFrame(flags byte, body []byte) error {
+	header := []byte{flags}
+	if len(body) > 255 {
+		header[0] |= FLAG_LONG
+		size := make([]byte, 8)
+		binary.BigEndian.PutUint64(size, uint64(len(body)))
+		header = append(header, size...)
+	} else {
+		header = append(header, byte(len(body)))
+	}
+	_, err := s.conn.Write(append(header, body...))
+	return err
+}
+
+func (s *Subscriber) readFrame() (byte, []byte, error) {
+	flags, err := s.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&FLAG_LONG != 0 {
+		if err := binary.Read(s.reader, binary.BigEndian, &size); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		short, err := s.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(short)
+	}
+	if size > MAX_FRAME_SIZE {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// Receive blocks until the next multipart message arrives. A zero `timeout` waits forever,
+// otherwise a net.Error with Timeout() set is returned when nothing arrives in time
+func (s *Subscriber) Receive(timeout time.Duration) ([][]byte, error) {
+	if timeout > 0 {
+		s.conn.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		s.conn.SetReadDeadline(time.Time{})
+	}
+
+	var parts [][]byte
+	for {
+		flags, body, err := s.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&FLAG_COMMAND != 0 {
+			// e.g. PING from ZMTP 3.1 peers, nothing a subscriber has to answer
+			continue
+		}
+		parts = append(parts, body)
+		if flags&FLAG_MORE == 0 {
+			return parts, nil
+		}
+	}
+}
+
+func (s *Subscriber) Close() error {
+	return s.conn.Close()
+}
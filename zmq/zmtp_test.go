@@ -0,0 +1,124 @@
+package zmq_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ABMatrix/bitcoin-utxo-ms/zmq"
+	"github.com/ABMatrix/bitcoin-utxo-ms/zmq/zmqtest"
+)
+
+func TestSubscriberReceivesMultipartMessages(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	subscriber, err := zmq.Dial(context.Background(), p.Endpoint(), "hashblock", "rawblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	c := p.Accept(t)
+	topics, err := c.Subscriptions(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(topics, ",") != "hashblock,rawblock" {
+		t.Fatalf("unexpected subscriptions %v", topics)
+	}
+
+	rawBlock := bytes.Repeat([]byte{0xab}, 300) // long frame
+	sequence := []byte{1, 0, 0, 0}
+	tests := []struct {
+		topic string
+		body  []byte
+	}{
+		{"hashblock", bytes.Repeat([]byte{0x11}, 32)},
+		{"rawblock", rawBlock},
+	}
+	for _, test := range tests {
+		if err := c.Send([]byte(test.topic), test.body, sequence); err != nil {
+			t.Fatal(err)
+		}
+		parts, err := subscriber.Receive(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parts) != 3 || string(parts[0]) != test.topic || !bytes.Equal(parts[1], test.body) || !bytes.Equal(parts[2], sequence) {
+			t.Fatalf("unexpected message for %s: %q", test.topic, parts)
+		}
+	}
+}
+
+func TestSubscriberSkipsCommands(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	subscriber, err := zmq.Dial(context.Background(), p.Endpoint(), "hashblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	c := p.Accept(t)
+	c.WriteFrame(zmq.FLAG_COMMAND, []byte("\x04PING"))
+	c.Send([]byte("hashblock"), []byte{0x22})
+	parts, err := subscriber.Receive(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || string(parts[0]) != "hashblock" {
+		t.Fatalf("unexpected message %q", parts)
+	}
+}
+
+func TestSubscriberReconnectsAfterPublisherDrops(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	subscriber, err := zmq.Dial(context.Background(), p.Endpoint(), "hashblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Accept(t).Close()
+	if _, err := subscriber.Receive(5 * time.Second); err == nil {
+		t.Fatal("expected an error once the publisher dropped the connection")
+	}
+	subscriber.Close()
+
+	subscriber, err = zmq.Dial(context.Background(), p.Endpoint(), "hashblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+	c := p.Accept(t)
+	c.Send([]byte("hashblock"), []byte{0x33})
+	parts, err := subscriber.Receive(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || !bytes.Equal(parts[1], []byte{0x33}) {
+		t.Fatalf("unexpected message %q", parts)
+	}
+}
+
+func TestReceiveTimesOut(t *testing.T) {
+	p := zmqtest.NewPublisher(t)
+	subscriber, err := zmq.Dial(context.Background(), p.Endpoint(), "hashblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+	p.Accept(t)
+
+	_, err = subscriber.Receive(50 * time.Millisecond)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestDialRejectsOtherTransports(t *testing.T) {
+	if _, err := zmq.Dial(context.Background(), "ipc:///tmp/bitcoind"); err == nil {
+		t.Fatal("expected ipc endpoints to be rejected")
+	}
+}